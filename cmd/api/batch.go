@@ -0,0 +1,199 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/noonacedia/cinematrique/internal/data"
+	"github.com/noonacedia/cinematrique/internal/validator"
+)
+
+var batchOps = []string{"create", "update", "delete"}
+
+type batchOperation struct {
+	Op      string      `json:"op"`
+	ID      int64       `json:"id"`
+	Version *int32      `json:"version"`
+	Movie   *movieInput `json:"movie"`
+	Changes *moviePatch `json:"changes"`
+}
+
+type batchResult struct {
+	Op     string      `json:"op"`
+	Status string      `json:"status"`
+	ID     int64       `json:"id,omitempty"`
+	Movie  *data.Movie `json:"movie,omitempty"`
+	Error  any         `json:"error,omitempty"`
+}
+
+type batchFailure struct {
+	status  int
+	message any
+}
+
+func (f *batchFailure) Error() string {
+	return fmt.Sprint(f.message)
+}
+
+func validateBatchOperation(v *validator.Validator, op *batchOperation) {
+	v.Check(validator.In(op.Op, batchOps...), "op", "must be one of: "+strings.Join(batchOps, ", "))
+	switch op.Op {
+	case "create":
+		v.Check(op.Movie != nil, "movie", "must be provided")
+		v.Check(op.ID == 0, "id", "must not be provided")
+		v.Check(op.Changes == nil, "changes", "must not be provided")
+	case "update":
+		v.Check(op.ID > 0, "id", "must be provided")
+		v.Check(op.Version != nil, "version", "must be provided")
+		v.Check(op.Changes != nil, "changes", "must be provided")
+		v.Check(op.Movie == nil, "movie", "must not be provided")
+	case "delete":
+		v.Check(op.ID > 0, "id", "must be provided")
+		v.Check(op.Movie == nil, "movie", "must not be provided")
+		v.Check(op.Changes == nil, "changes", "must not be provided")
+	}
+}
+
+func (app *application) batchHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Operations []*batchOperation `json:"operations"`
+	}
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	v := validator.New()
+	v.Check(len(input.Operations) > 0, "operations", "must contain at least 1 operation")
+	v.Check(len(input.Operations) <= 100, "operations", "must not contain more than 100 operations")
+	for i, op := range input.Operations {
+		if op == nil {
+			v.AddError(fmt.Sprintf("operations[%d]", i), "must be an object")
+			continue
+		}
+		ov := validator.New()
+		validateBatchOperation(ov, op)
+		for key, msg := range ov.Errors {
+			v.AddError(fmt.Sprintf("operations[%d].%s", i, key), msg)
+		}
+	}
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+	results := make([]batchResult, len(input.Operations))
+	var deleted []int64
+	for i, op := range input.Operations {
+		results[i] = batchResult{Op: op.Op, Status: "skipped", ID: op.ID}
+		if op.Op == "delete" {
+			deleted = append(deleted, op.ID)
+		}
+	}
+	images, err := app.models.MovieImages.GetAllForMovies(deleted)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	failed := -1
+	err = app.models.Movies.Batch(func(b data.MovieBatch) error {
+		for i, op := range input.Operations {
+			movie, err := app.runBatchOperation(b, op)
+			if err != nil {
+				failed = i
+				return err
+			}
+			results[i].Status = op.Op + "d"
+			if movie != nil {
+				results[i].ID = movie.ID
+				results[i].Movie = movie
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		var failure *batchFailure
+		if !errors.As(err, &failure) {
+			// The database itself failed, either during an operation or
+			// while committing; the results still show what was undone.
+			app.logError(r, err)
+			failure = &batchFailure{status: http.StatusInternalServerError, message: serverErrorMessage}
+		}
+		for i := range results {
+			switch {
+			case i == failed:
+				results[i].Status = "failed"
+				results[i].Error = failure.message
+			case results[i].Status != "skipped":
+				results[i].Status = "rolled_back"
+			}
+			results[i].Movie = nil
+		}
+		msg := "the batch could not be committed, no changes were applied"
+		if failed >= 0 {
+			msg = fmt.Sprintf("operation %d failed, no changes were applied", failed)
+		}
+		err = app.writeJSON(w, failure.status, envelope{"error": msg, "results": results}, nil)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	for _, id := range deleted {
+		app.removeImageBlobs(images[id])
+	}
+	err = app.writeJSON(w, http.StatusOK, envelope{"results": results}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) runBatchOperation(b data.MovieBatch, op *batchOperation) (*data.Movie, error) {
+	var movie *data.Movie
+	var err error
+	switch op.Op {
+	case "create":
+		movie = op.Movie.movie()
+		v := validator.New()
+		if data.ValidateMovie(v, movie); !v.Valid() {
+			return nil, &batchFailure{status: http.StatusUnprocessableEntity, message: v.Errors}
+		}
+		err = b.Insert(movie)
+	case "update":
+		movie, err = b.Get(op.ID)
+		if err != nil {
+			break
+		}
+		if movie.Version != *op.Version {
+			err = data.ErrEditConflict
+			break
+		}
+		op.Changes.apply(movie)
+		v := validator.New()
+		if data.ValidateMovie(v, movie); !v.Valid() {
+			return nil, &batchFailure{status: http.StatusUnprocessableEntity, message: v.Errors}
+		}
+		err = b.Update(movie)
+	case "delete":
+		err = b.Delete(op.ID)
+	}
+	if err != nil {
+		var duplicate *data.DuplicateExternalIDError
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			return nil, &batchFailure{status: http.StatusNotFound, message: notFoundMessage}
+		case errors.Is(err, data.ErrEditConflict):
+			return nil, &batchFailure{status: http.StatusConflict, message: editConflictMessage}
+		case errors.As(err, &duplicate):
+			msg := fmt.Sprintf("a movie with %s id %q already exists", duplicate.Source, duplicate.Value)
+			return nil, &batchFailure{status: http.StatusConflict, message: msg}
+		case errors.Is(err, data.ErrDuplicateRelease):
+			msg := map[string]string{"releases": "must not contain more than one release per country and type"}
+			return nil, &batchFailure{status: http.StatusUnprocessableEntity, message: msg}
+		default:
+			return nil, err
+		}
+	}
+	return movie, nil
+}
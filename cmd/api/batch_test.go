@@ -0,0 +1,242 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"reflect"
+	"slices"
+	"testing"
+
+	"github.com/noonacedia/cinematrique/internal/data"
+)
+
+var errConnectionReset = errors.New("read tcp: connection reset by peer")
+
+// transactionalMovies applies each batch to a copy of its movies and keeps
+// the copy only if every operation and the commit succeed.
+type transactionalMovies struct {
+	data.MockMovieModel
+	movies map[int64]*data.Movie
+	// broken is a movie id whose reads and writes fail as if the database
+	// connection had dropped.
+	broken    int64
+	commitErr error
+}
+
+type memoryBatch struct {
+	movies map[int64]*data.Movie
+	broken int64
+}
+
+func (b memoryBatch) Get(id int64) (*data.Movie, error) {
+	if id == b.broken {
+		return nil, errConnectionReset
+	}
+	movie, ok := b.movies[id]
+	if !ok {
+		return nil, data.ErrRecordNotFound
+	}
+	copied := *movie
+	return &copied, nil
+}
+
+func (b memoryBatch) Insert(movie *data.Movie) error {
+	movie.ID = 1
+	for id := range b.movies {
+		movie.ID = max(movie.ID, id+1)
+	}
+	movie.Version = 1
+	copied := *movie
+	b.movies[movie.ID] = &copied
+	return nil
+}
+
+func (b memoryBatch) Update(movie *data.Movie) error {
+	stored, ok := b.movies[movie.ID]
+	if !ok || stored.Version != movie.Version {
+		return data.ErrEditConflict
+	}
+	movie.Version++
+	copied := *movie
+	b.movies[movie.ID] = &copied
+	return nil
+}
+
+func (b memoryBatch) Delete(id int64) error {
+	if id == b.broken {
+		return errConnectionReset
+	}
+	if _, ok := b.movies[id]; !ok {
+		return data.ErrRecordNotFound
+	}
+	delete(b.movies, id)
+	return nil
+}
+
+func (m *transactionalMovies) Batch(fn func(b data.MovieBatch) error) error {
+	staged := make(map[int64]*data.Movie, len(m.movies))
+	for id, movie := range m.movies {
+		copied := *movie
+		staged[id] = &copied
+	}
+	err := fn(memoryBatch{movies: staged, broken: m.broken})
+	if err != nil {
+		return err
+	}
+	if m.commitErr != nil {
+		return m.commitErr
+	}
+	m.movies = staged
+	return nil
+}
+
+func newBatchTestMovies() *transactionalMovies {
+	return &transactionalMovies{movies: map[int64]*data.Movie{
+		1: {ID: 1, Type: "movie", Title: "Alien", Year: 1979, Runtime: 117, Genres: []string{"horror"}, Version: 1},
+		2: {ID: 2, Type: "movie", Title: "Aliens", Year: 1986, Runtime: 137, Genres: []string{"action"}, Version: 3},
+	}}
+}
+
+type batchResponse struct {
+	Error   any `json:"error"`
+	Results []struct {
+		Op     string      `json:"op"`
+		Status string      `json:"status"`
+		ID     int64       `json:"id"`
+		Movie  *data.Movie `json:"movie"`
+		Error  any         `json:"error"`
+	} `json:"results"`
+}
+
+func postBatch(t *testing.T, app *application, body string) (int, batchResponse) {
+	t.Helper()
+	spec := loadOpenAPI(t)
+	spec.checkRequest(t, "POST /v1/batch", body)
+	rr := serve(t, app.routes(), http.MethodPost, "/v1/batch", body, nil)
+	spec.checkResponse(t, "POST /v1/batch", rr.Code, rr.Header(), rr.Body.Bytes())
+	var res batchResponse
+	err := json.Unmarshal(rr.Body.Bytes(), &res)
+	if err != nil {
+		t.Fatalf("%v: %s", err, rr.Body)
+	}
+	return rr.Code, res
+}
+
+func TestBatchAppliesEveryOperation(t *testing.T) {
+	app := newTestApplication(t)
+	movies := newBatchTestMovies()
+	app.models.Movies = movies
+
+	status, res := postBatch(t, app, `{"operations":[
+		{"op":"create","movie":{"title":"Up","year":2009,"runtime":96,"genres":["animation"]}},
+		{"op":"update","id":1,"version":1,"changes":{"title":"Alien (Director's Cut)"}},
+		{"op":"delete","id":2}
+	]}`)
+	if status != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %+v", status, http.StatusOK, res)
+	}
+	var statuses []string
+	for _, result := range res.Results {
+		statuses = append(statuses, result.Status)
+	}
+	if want := []string{"created", "updated", "deleted"}; !slices.Equal(statuses, want) {
+		t.Errorf("statuses = %v, want %v", statuses, want)
+	}
+	if res.Results[0].ID != 3 || res.Results[0].Movie == nil {
+		t.Errorf("create result = %+v, want movie 3", res.Results[0])
+	}
+	if movie := movies.movies[1]; movie.Title != "Alien (Director's Cut)" || movie.Version != 2 {
+		t.Errorf("updated movie = %+v", movie)
+	}
+	if _, ok := movies.movies[2]; ok {
+		t.Error("deleted movie is still stored")
+	}
+	if _, ok := movies.movies[3]; !ok {
+		t.Error("created movie was not stored")
+	}
+}
+
+func TestBatchRollsBackOnFailure(t *testing.T) {
+	create := `{"op":"create","movie":{"title":"Up","year":2009,"runtime":96,"genres":["animation"]}}`
+	update := `{"op":"update","id":1,"version":1,"changes":{"year":1980}}`
+	tests := []struct {
+		name         string
+		operations   string
+		broken       int64
+		commitErr    error
+		wantStatus   int
+		wantStatuses []string
+		wantError    any
+	}{
+		{
+			name:         "stale version",
+			operations:   create + `,{"op":"update","id":2,"version":2,"changes":{"year":1987}},` + update,
+			wantStatus:   http.StatusConflict,
+			wantStatuses: []string{"rolled_back", "failed", "skipped"},
+			wantError:    editConflictMessage,
+		},
+		{
+			name:         "missing movie",
+			operations:   update + `,{"op":"delete","id":9}`,
+			wantStatus:   http.StatusNotFound,
+			wantStatuses: []string{"rolled_back", "failed"},
+			wantError:    notFoundMessage,
+		},
+		{
+			name:         "invalid change",
+			operations:   create + `,{"op":"update","id":1,"version":1,"changes":{"title":""}}`,
+			wantStatus:   http.StatusUnprocessableEntity,
+			wantStatuses: []string{"rolled_back", "failed"},
+			wantError:    map[string]any{"title": "must be provided"},
+		},
+		{
+			name:         "database error",
+			operations:   update + `,{"op":"delete","id":2},` + create,
+			broken:       2,
+			wantStatus:   http.StatusInternalServerError,
+			wantStatuses: []string{"rolled_back", "failed", "skipped"},
+			wantError:    serverErrorMessage,
+		},
+		{
+			name:         "commit error",
+			operations:   create + "," + update,
+			commitErr:    errConnectionReset,
+			wantStatus:   http.StatusInternalServerError,
+			wantStatuses: []string{"rolled_back", "rolled_back"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(t)
+			movies := newBatchTestMovies()
+			movies.broken, movies.commitErr = tt.broken, tt.commitErr
+			before := newBatchTestMovies().movies
+			app.models.Movies = movies
+
+			status, res := postBatch(t, app, `{"operations":[`+tt.operations+`]}`)
+			if status != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %+v", status, tt.wantStatus, res)
+			}
+			if res.Error == nil {
+				t.Error("response has no error message")
+			}
+			var statuses []string
+			for i, result := range res.Results {
+				statuses = append(statuses, result.Status)
+				if result.Movie != nil {
+					t.Errorf("result %d still carries a movie that was not saved", i)
+				}
+				if result.Status == "failed" && !reflect.DeepEqual(result.Error, tt.wantError) {
+					t.Errorf("result %d error = %#v, want %#v", i, result.Error, tt.wantError)
+				}
+			}
+			if !slices.Equal(statuses, tt.wantStatuses) {
+				t.Errorf("statuses = %v, want %v", statuses, tt.wantStatuses)
+			}
+			if !reflect.DeepEqual(movies.movies, before) {
+				t.Errorf("movies changed despite the rollback: %+v", movies.movies)
+			}
+		})
+	}
+}
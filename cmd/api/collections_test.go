@@ -0,0 +1,248 @@
+package main
+
+import (
+	"encoding/json"
+	"maps"
+	"net/http"
+	"slices"
+	"sync"
+	"testing"
+
+	"github.com/noonacedia/cinematrique/internal/data"
+)
+
+// memoryCollections keeps collections in memory, positioning members in the
+// order they were given as the collection_movies table does.
+type memoryCollections struct {
+	data.MockCollectionModel
+	mu          sync.Mutex
+	collections map[int64]*data.Collection
+	movies      map[int64]*data.Movie
+	// stale makes the next Update lose the race to a concurrent writer.
+	stale bool
+}
+
+func newMemoryCollections(movies ...*data.Movie) *memoryCollections {
+	m := &memoryCollections{collections: make(map[int64]*data.Collection), movies: make(map[int64]*data.Movie)}
+	for _, movie := range movies {
+		m.movies[movie.ID] = movie
+	}
+	return m
+}
+
+func (m *memoryCollections) save(collection *data.Collection) error {
+	for _, id := range collection.MovieIDs {
+		if m.movies[id] == nil {
+			return data.ErrUnknownCollectionMovie
+		}
+	}
+	stored := *collection
+	stored.MovieIDs = slices.Clone(collection.MovieIDs)
+	m.collections[collection.ID] = &stored
+	return nil
+}
+
+func (m *memoryCollections) Insert(collection *data.Collection) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	collection.ID = int64(len(m.collections) + 1)
+	collection.Version = 1
+	return m.save(collection)
+}
+
+func (m *memoryCollections) Get(id int64) (*data.Collection, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	stored, ok := m.collections[id]
+	if !ok {
+		return nil, data.ErrRecordNotFound
+	}
+	collection := *stored
+	collection.MovieIDs = slices.Clone(stored.MovieIDs)
+	return &collection, nil
+}
+
+func (m *memoryCollections) GetMovies(id int64, filters data.Filters) ([]*data.Movie, data.Metadata, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	movies := make([]*data.Movie, 0)
+	for _, movieID := range m.collections[id].MovieIDs {
+		movies = append(movies, m.movies[movieID])
+	}
+	if filters.Sort == "-position" {
+		slices.Reverse(movies)
+	}
+	return movies, data.Metadata{}, nil
+}
+
+func (m *memoryCollections) Update(collection *data.Collection) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	stored, ok := m.collections[collection.ID]
+	if !ok || m.stale || stored.Version != collection.Version {
+		return data.ErrEditConflict
+	}
+	collection.Version++
+	return m.save(collection)
+}
+
+func newCollectionTestApplication(t *testing.T) (*application, *memoryCollections) {
+	t.Helper()
+	app := newTestApplication(t)
+	collections := newMemoryCollections(
+		&data.Movie{ID: 1, Title: "Alien", Year: 1979},
+		&data.Movie{ID: 2, Title: "Aliens", Year: 1986},
+		&data.Movie{ID: 3, Title: "Alien 3", Year: 1992},
+	)
+	app.models.Collections = collections
+	return app, collections
+}
+
+type collectionResponse struct {
+	Collection data.Collection   `json:"collection"`
+	Movies     []data.Movie      `json:"movies"`
+	Error      map[string]string `json:"error"`
+}
+
+func decodeCollection(t *testing.T, body []byte) collectionResponse {
+	t.Helper()
+	var res collectionResponse
+	err := json.Unmarshal(body, &res)
+	if err != nil {
+		t.Fatalf("%v: %s", err, body)
+	}
+	return res
+}
+
+func TestCreateCollection(t *testing.T) {
+	tests := []struct {
+		name       string
+		body       string
+		wantStatus int
+		wantIDs    []int64
+		wantError  map[string]string
+	}{
+		{"keeps the given order", `{"name":"Alien","movie_ids":[3,1,2]}`, http.StatusCreated, []int64{3, 1, 2}, nil},
+		{"empty", `{"name":"Alien"}`, http.StatusCreated, []int64{}, nil},
+		{"missing name", `{"movie_ids":[1]}`, http.StatusUnprocessableEntity, nil, map[string]string{"name": "must be provided"}},
+		{"duplicate member", `{"name":"Alien","movie_ids":[1,2,1]}`, http.StatusUnprocessableEntity, nil, map[string]string{"movie_ids": "must not contain duplicate values"}},
+		{"non-positive member", `{"name":"Alien","movie_ids":[0]}`, http.StatusUnprocessableEntity, nil, map[string]string{"movie_ids": "must contain only positive ids"}},
+		{"unknown member", `{"name":"Alien","movie_ids":[1,9]}`, http.StatusUnprocessableEntity, nil, map[string]string{"movie_ids": "must reference existing movies"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app, _ := newCollectionTestApplication(t)
+			rr := serve(t, app.routes(), http.MethodPost, "/v1/collections", tt.body, nil)
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", rr.Code, tt.wantStatus, rr.Body)
+			}
+			res := decodeCollection(t, rr.Body.Bytes())
+			if tt.wantError != nil {
+				if !maps.Equal(res.Error, tt.wantError) {
+					t.Errorf("errors = %v, want %v", res.Error, tt.wantError)
+				}
+				return
+			}
+			if !slices.Equal(res.Collection.MovieIDs, tt.wantIDs) {
+				t.Errorf("movie_ids = %v, want %v", res.Collection.MovieIDs, tt.wantIDs)
+			}
+		})
+	}
+}
+
+func TestUpdateCollectionReordersMovies(t *testing.T) {
+	app, _ := newCollectionTestApplication(t)
+	h := app.routes()
+	rr := serve(t, h, http.MethodPost, "/v1/collections", `{"name":"Alien","movie_ids":[1,2,3]}`, nil)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("create status = %d: %s", rr.Code, rr.Body)
+	}
+
+	rr = serve(t, h, http.MethodPatch, "/v1/collections/1", `{"movie_ids":[3,1]}`, nil)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("update status = %d: %s", rr.Code, rr.Body)
+	}
+	res := decodeCollection(t, rr.Body.Bytes())
+	if !slices.Equal(res.Collection.MovieIDs, []int64{3, 1}) || res.Collection.Version != 2 {
+		t.Errorf("collection = %+v, want movies [3 1] at version 2", res.Collection)
+	}
+
+	tests := []struct {
+		target string
+		want   []int64
+	}{
+		{"/v1/collections/1/movies", []int64{3, 1}},
+		{"/v1/collections/1/movies?sort=-position", []int64{1, 3}},
+	}
+	for _, tt := range tests {
+		rr := serve(t, h, http.MethodGet, tt.target, "", nil)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("GET %s status = %d: %s", tt.target, rr.Code, rr.Body)
+		}
+		var ids []int64
+		for _, movie := range decodeCollection(t, rr.Body.Bytes()).Movies {
+			ids = append(ids, movie.ID)
+		}
+		if !slices.Equal(ids, tt.want) {
+			t.Errorf("GET %s movies = %v, want %v", tt.target, ids, tt.want)
+		}
+	}
+
+	// Leaving movie_ids out keeps the members and their positions.
+	rr = serve(t, h, http.MethodPatch, "/v1/collections/1", `{"name":"Alien films"}`, nil)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("rename status = %d: %s", rr.Code, rr.Body)
+	}
+	if res := decodeCollection(t, rr.Body.Bytes()); !slices.Equal(res.Collection.MovieIDs, []int64{3, 1}) {
+		t.Errorf("movie_ids after rename = %v, want [3 1]", res.Collection.MovieIDs)
+	}
+}
+
+func TestUpdateCollectionConflicts(t *testing.T) {
+	tests := []struct {
+		name       string
+		target     string
+		body       string
+		stale      bool
+		wantStatus int
+		wantError  map[string]string
+	}{
+		{"duplicate member", "/v1/collections/1", `{"movie_ids":[2,2]}`, false, http.StatusUnprocessableEntity, map[string]string{"movie_ids": "must not contain duplicate values"}},
+		{"unknown member", "/v1/collections/1", `{"movie_ids":[2,9]}`, false, http.StatusUnprocessableEntity, map[string]string{"movie_ids": "must reference existing movies"}},
+		{"concurrent edit", "/v1/collections/1", `{"movie_ids":[2]}`, true, http.StatusConflict, nil},
+		{"unknown collection", "/v1/collections/2", `{"movie_ids":[2]}`, false, http.StatusNotFound, nil},
+		{"unknown collection movies", "/v1/collections/2/movies", "", false, http.StatusNotFound, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app, collections := newCollectionTestApplication(t)
+			h := app.routes()
+			rr := serve(t, h, http.MethodPost, "/v1/collections", `{"name":"Alien","movie_ids":[1,2]}`, nil)
+			if rr.Code != http.StatusCreated {
+				t.Fatalf("create status = %d: %s", rr.Code, rr.Body)
+			}
+			collections.stale = tt.stale
+
+			method := http.MethodPatch
+			if tt.body == "" {
+				method = http.MethodGet
+			}
+			rr = serve(t, h, method, tt.target, tt.body, nil)
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", rr.Code, tt.wantStatus, rr.Body)
+			}
+			if tt.wantError != nil {
+				if res := decodeCollection(t, rr.Body.Bytes()); !maps.Equal(res.Error, tt.wantError) {
+					t.Errorf("errors = %v, want %v", res.Error, tt.wantError)
+				}
+			}
+			stored, err := collections.Get(1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(stored.MovieIDs, []int64{1, 2}) || stored.Version != 1 {
+				t.Errorf("stored collection = %+v, want it unchanged", stored)
+			}
+		})
+	}
+}
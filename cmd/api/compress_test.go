@@ -0,0 +1,163 @@
+package main
+
+import (
+	"bufio"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func gunzip(t *testing.T, rr *httptest.ResponseRecorder) string {
+	t.Helper()
+	if rr.Header().Get("Content-Encoding") != "gzip" {
+		return rr.Body.String()
+	}
+	zr, err := gzip.NewReader(rr.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := io.ReadAll(zr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(body)
+}
+
+func TestCompressSmallBodies(t *testing.T) {
+	app := newTestApplication(t)
+	h := app.compress(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, r.URL.Query().Get("body"))
+	}))
+	large := strings.Repeat("a", app.config.compress.minSize)
+	tests := []struct {
+		name           string
+		body           string
+		acceptEncoding string
+		wantEncoding   string
+	}{
+		{"small body", "small", "gzip", ""},
+		{"large body", large, "gzip", "gzip"},
+		{"preferred coding", large, "gzip;q=0.5, zstd", "zstd"},
+		{"no accepted coding", large, "", ""},
+		{"identity only", large, "gzip;q=0, identity", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := serve(t, h, http.MethodGet, "/?body="+tt.body, "", http.Header{"Accept-Encoding": {tt.acceptEncoding}})
+			if got := rr.Header().Get("Content-Encoding"); got != tt.wantEncoding {
+				t.Errorf("Content-Encoding = %q, want %q", got, tt.wantEncoding)
+			}
+			if !slices.Contains(rr.Header().Values("Vary"), "Accept-Encoding") {
+				t.Errorf("Vary = %q, want Accept-Encoding", rr.Header().Values("Vary"))
+			}
+			if tt.wantEncoding == "" && rr.Body.String() != tt.body {
+				t.Errorf("body was altered without a Content-Encoding")
+			}
+			if tt.wantEncoding == "gzip" && gunzip(t, rr) != tt.body {
+				t.Errorf("gzip body does not decompress to the original")
+			}
+		})
+	}
+}
+
+func TestCompressFlushesStreams(t *testing.T) {
+	app := newTestApplication(t)
+	release := make(chan struct{})
+	srv := httptest.NewServer(app.compress(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		io.WriteString(w, "data: first\n\n")
+		http.NewResponseController(w).Flush()
+		<-release
+	})))
+	defer srv.Close()
+	// Runs before srv.Close, which waits for the handler to return.
+	defer close(release)
+
+	req, err := http.NewRequest(http.MethodGet, srv.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Setting Accept-Encoding stops the transport decompressing for us.
+	req.Header.Set("Accept-Encoding", "gzip")
+	res, err := srv.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	if res.Header.Get("Content-Encoding") != "gzip" {
+		t.Fatalf("Content-Encoding = %q, want gzip", res.Header.Get("Content-Encoding"))
+	}
+
+	line := make(chan string, 1)
+	go func() {
+		zr, err := gzip.NewReader(res.Body)
+		if err != nil {
+			line <- err.Error()
+			return
+		}
+		s, _ := bufio.NewReader(zr).ReadString('\n')
+		line <- s
+	}()
+	select {
+	case got := <-line:
+		if got != "data: first\n" {
+			t.Errorf("first line = %q, want the flushed event", got)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("flushed event did not reach the client while the handler was running")
+	}
+}
+
+func TestIdempotentReplayThroughCompress(t *testing.T) {
+	app := newTestApplication(t)
+	app.config.compress.minSize = 1
+	keys := newMemoryIdempotencyKeys()
+	app.models.IdempotencyKeys = keys
+	h := app.routes()
+	post := func(acceptEncoding string) *httptest.ResponseRecorder {
+		t.Helper()
+		header := http.Header{"Idempotency-Key": {"compressed"}}
+		if acceptEncoding != "" {
+			header.Set("Accept-Encoding", acceptEncoding)
+		}
+		return serve(t, h, http.MethodPost, "/v1/movies", `{"title":"Up","runtime":96,"genres":["animation"],"year":2009}`, header)
+	}
+
+	first := post("gzip")
+	if first.Code != http.StatusCreated || first.Header().Get("Content-Encoding") != "gzip" {
+		t.Fatalf("first response = %d %q, want a gzipped 201", first.Code, first.Header().Get("Content-Encoding"))
+	}
+	want := gunzip(t, first)
+	for _, record := range keys.records {
+		for _, name := range []string{"Content-Encoding", "Content-Length"} {
+			if values := http.Header(record.Header).Values(name); len(values) > 0 {
+				t.Errorf("recorded %s = %q", name, values)
+			}
+		}
+	}
+
+	for _, acceptEncoding := range []string{"gzip", "zstd", ""} {
+		replay := post(acceptEncoding)
+		if replay.Header().Get("Idempotent-Replayed") != "true" {
+			t.Fatalf("Accept-Encoding %q: response was not a replay", acceptEncoding)
+		}
+		if acceptEncoding == "zstd" {
+			if replay.Header().Get("Content-Encoding") != "zstd" {
+				t.Errorf("Accept-Encoding zstd: Content-Encoding = %q", replay.Header().Get("Content-Encoding"))
+			}
+			continue
+		}
+		if got := gunzip(t, replay); got != want {
+			t.Errorf("Accept-Encoding %q: replay body = %q, want %q", acceptEncoding, got, want)
+		}
+		if vary := replay.Header().Values("Vary"); !slices.Equal(vary, first.Header().Values("Vary")) {
+			t.Errorf("Accept-Encoding %q: Vary = %q, want %q", acceptEncoding, vary, first.Header().Values("Vary"))
+		}
+	}
+}
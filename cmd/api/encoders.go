@@ -0,0 +1,344 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"mime"
+	"net/http"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/noonacedia/cinematrique/internal/msgpack"
+)
+
+var (
+	errNotAcceptable        = errors.New("no acceptable representation")
+	errUnrepresentableShape = errors.New("envelope cannot be represented in this format")
+)
+
+type responseEncoder struct {
+	// The first media type is the one written in Content-Type.
+	mediaTypes []string
+	encode     func(h http.Header, jsonText []byte, params map[string]string) ([]byte, error)
+}
+
+// The order decides which encoder wins when the client sends a wildcard.
+var responseEncoders = []*responseEncoder{
+	{mediaTypes: []string{"application/json"}, encode: encodeJSON},
+	{mediaTypes: []string{"application/xml", "text/xml"}, encode: encodeXML},
+	{mediaTypes: []string{"text/csv"}, encode: encodeCSV},
+	{mediaTypes: []string{"application/msgpack", "application/vnd.msgpack", "application/x-msgpack"}, encode: encodeMsgpack},
+}
+
+type mediaRange struct {
+	mediaType string
+	params    map[string]string
+	q         float64
+}
+
+func (m mediaRange) matches(mediaType string) bool {
+	switch {
+	case m.mediaType == "*/*":
+		return true
+	case strings.HasSuffix(m.mediaType, "/*"):
+		return strings.HasPrefix(mediaType, strings.TrimSuffix(m.mediaType, "*"))
+	default:
+		return m.mediaType == mediaType
+	}
+}
+
+func parseAccept(header string) []mediaRange {
+	var ranges []mediaRange
+	for _, part := range strings.Split(header, ",") {
+		if strings.TrimSpace(part) == "" {
+			continue
+		}
+		mediaType, params, err := mime.ParseMediaType(part)
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if value, ok := params["q"]; ok {
+			q, err = strconv.ParseFloat(value, 64)
+			if err != nil || q < 0 || q > 1 {
+				continue
+			}
+			delete(params, "q")
+		}
+		ranges = append(ranges, mediaRange{mediaType: mediaType, params: params, q: q})
+	}
+	slices.SortStableFunc(ranges, func(a, b mediaRange) int {
+		switch {
+		case a.q > b.q:
+			return -1
+		case a.q < b.q:
+			return 1
+		}
+		return strings.Count(b.mediaType, "*") - strings.Count(a.mediaType, "*")
+	})
+	return ranges
+}
+
+type negotiatedEncoder struct {
+	encoder *responseEncoder
+	params  map[string]string
+}
+
+// negotiateEncoders lists the encoders acceptable to the client, most
+// preferred first. An empty Accept header means anything is acceptable.
+func negotiateEncoders(accept string) []negotiatedEncoder {
+	if strings.TrimSpace(accept) == "" {
+		return []negotiatedEncoder{{encoder: responseEncoders[0]}}
+	}
+	ranges := parseAccept(accept)
+	refused := func(mediaType string) bool {
+		return slices.ContainsFunc(ranges, func(m mediaRange) bool {
+			return m.q == 0 && m.mediaType == mediaType
+		})
+	}
+	var negotiated []negotiatedEncoder
+	for _, m := range ranges {
+		if m.q == 0 {
+			continue
+		}
+		for _, encoder := range responseEncoders {
+			if refused(encoder.mediaTypes[0]) || !slices.ContainsFunc(encoder.mediaTypes, m.matches) {
+				continue
+			}
+			if slices.ContainsFunc(negotiated, func(n negotiatedEncoder) bool { return n.encoder == encoder }) {
+				continue
+			}
+			negotiated = append(negotiated, negotiatedEncoder{encoder: encoder, params: m.params})
+		}
+	}
+	return negotiated
+}
+
+// encodeResponse renders the JSON form of an envelope with the first
+// negotiated encoder able to represent it. Error responses fall back to JSON
+// rather than hiding the error behind a 406.
+func encodeResponse(w http.ResponseWriter, status int, jsonText []byte) ([]byte, string, error) {
+	negotiated := []negotiatedEncoder{{encoder: responseEncoders[0]}}
+	for rw := w; rw != nil; {
+		if nw, ok := rw.(*negotiatedWriter); ok {
+			negotiated = nw.encoders
+			break
+		}
+		u, ok := rw.(interface{ Unwrap() http.ResponseWriter })
+		if !ok {
+			break
+		}
+		rw = u.Unwrap()
+	}
+	for _, n := range negotiated {
+		body, err := n.encoder.encode(w.Header(), jsonText, n.params)
+		if errors.Is(err, errUnrepresentableShape) {
+			continue
+		}
+		if err != nil {
+			return nil, "", err
+		}
+		return body, n.encoder.mediaTypes[0], nil
+	}
+	if status >= http.StatusBadRequest {
+		return append(jsonText, '\n'), "application/json", nil
+	}
+	return nil, "", errNotAcceptable
+}
+
+func decodeEnvelope(jsonText []byte) (map[string]any, error) {
+	decoder := json.NewDecoder(bytes.NewReader(jsonText))
+	decoder.UseNumber()
+	var env map[string]any
+	err := decoder.Decode(&env)
+	if err != nil {
+		return nil, err
+	}
+	return env, nil
+}
+
+func encodeJSON(h http.Header, jsonText []byte, params map[string]string) ([]byte, error) {
+	if pretty, _ := strconv.ParseBool(params["pretty"]); pretty {
+		var buf bytes.Buffer
+		err := json.Indent(&buf, jsonText, "", "\t")
+		if err != nil {
+			return nil, err
+		}
+		buf.WriteByte('\n')
+		return buf.Bytes(), nil
+	}
+	return append(jsonText, '\n'), nil
+}
+
+var xmlNameRX = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.-]*$`)
+
+func encodeXML(h http.Header, jsonText []byte, params map[string]string) ([]byte, error) {
+	env, err := decodeEnvelope(jsonText)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+	encoder := xml.NewEncoder(&buf)
+	err = encodeXMLValue(encoder, "envelope", env)
+	if err != nil {
+		return nil, err
+	}
+	err = encoder.Flush()
+	if err != nil {
+		return nil, err
+	}
+	buf.WriteByte('\n')
+	return buf.Bytes(), nil
+}
+
+func encodeXMLValue(encoder *xml.Encoder, name string, value any) error {
+	start := xml.StartElement{Name: xml.Name{Local: name}}
+	// Keys such as validation error fields are not always valid element names.
+	if !xmlNameRX.MatchString(name) || strings.HasPrefix(strings.ToLower(name), "xml") {
+		start = xml.StartElement{Name: xml.Name{Local: "entry"}, Attr: []xml.Attr{{Name: xml.Name{Local: "key"}, Value: name}}}
+	}
+	err := encoder.EncodeToken(start)
+	if err != nil {
+		return err
+	}
+	switch value := value.(type) {
+	case map[string]any:
+		keys := make([]string, 0, len(value))
+		for key := range value {
+			keys = append(keys, key)
+		}
+		slices.Sort(keys)
+		for _, key := range keys {
+			err = encodeXMLValue(encoder, key, value[key])
+			if err != nil {
+				return err
+			}
+		}
+	case []any:
+		for _, item := range value {
+			err = encodeXMLValue(encoder, "item", item)
+			if err != nil {
+				return err
+			}
+		}
+	case nil:
+	default:
+		err = encoder.EncodeToken(xml.CharData(scalarString(value)))
+		if err != nil {
+			return err
+		}
+	}
+	return encoder.EncodeToken(start.End())
+}
+
+// encodeCSV writes the single list in an envelope as rows. The remaining
+// members, such as metadata, are sent as JSON in Envelope-<Key> headers.
+func encodeCSV(h http.Header, jsonText []byte, params map[string]string) ([]byte, error) {
+	env, err := decodeEnvelope(jsonText)
+	if err != nil {
+		return nil, err
+	}
+	var listKey string
+	for key, value := range env {
+		items, ok := value.([]any)
+		if !ok || slices.ContainsFunc(items, func(item any) bool { return !isObject(item) }) {
+			continue
+		}
+		if listKey != "" {
+			return nil, errUnrepresentableShape
+		}
+		listKey = key
+	}
+	if listKey == "" {
+		return nil, errUnrepresentableShape
+	}
+
+	rows := env[listKey].([]any)
+	var columns []string
+	for _, row := range rows {
+		for key := range row.(map[string]any) {
+			if !slices.Contains(columns, key) {
+				columns = append(columns, key)
+			}
+		}
+	}
+	slices.SortFunc(columns, func(a, b string) int {
+		switch {
+		case a == "id":
+			return -1
+		case b == "id":
+			return 1
+		}
+		return strings.Compare(a, b)
+	})
+
+	var buf bytes.Buffer
+	writer := csv.NewWriter(&buf)
+	if len(columns) > 0 {
+		err = writer.Write(columns)
+		if err != nil {
+			return nil, err
+		}
+	}
+	for _, row := range rows {
+		record := make([]string, len(columns))
+		for i, column := range columns {
+			record[i] = scalarString(row.(map[string]any)[column])
+		}
+		err = writer.Write(record)
+		if err != nil {
+			return nil, err
+		}
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return nil, err
+	}
+
+	for key, value := range env {
+		if key == listKey {
+			continue
+		}
+		js, err := json.Marshal(value)
+		if err != nil {
+			return nil, err
+		}
+		h.Set("Envelope-"+key, string(js))
+	}
+	return buf.Bytes(), nil
+}
+
+func isObject(value any) bool {
+	_, ok := value.(map[string]any)
+	return ok
+}
+
+func scalarString(value any) string {
+	switch value := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return value
+	case json.Number:
+		return value.String()
+	case bool:
+		return strconv.FormatBool(value)
+	default:
+		js, _ := json.Marshal(value)
+		return string(js)
+	}
+}
+
+func encodeMsgpack(h http.Header, jsonText []byte, params map[string]string) ([]byte, error) {
+	env, err := decodeEnvelope(jsonText)
+	if err != nil {
+		return nil, err
+	}
+	return msgpack.Marshal(env)
+}
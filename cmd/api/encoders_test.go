@@ -0,0 +1,133 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/noonacedia/cinematrique/internal/data"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+func TestEncodeCSVRejectsUnrepresentableShapes(t *testing.T) {
+	tests := []struct {
+		name     string
+		envelope string
+	}{
+		{"single object", `{"movie":{"id":1}}`},
+		{"list of scalars", `{"genres":["drama","comedy"]}`},
+		{"two lists", `{"movies":[{"id":1}],"similar":[{"id":2}]}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := encodeCSV(make(http.Header), []byte(tt.envelope), nil)
+			if !errors.Is(err, errUnrepresentableShape) {
+				t.Errorf("err = %v, want errUnrepresentableShape", err)
+			}
+		})
+	}
+}
+
+func TestNegotiatedResponses(t *testing.T) {
+	movie := &data.Movie{ID: 1, Type: "movie", Title: "Moana", Year: 2016, Runtime: 107, Genres: []string{"animation"}, Version: 1}
+	tests := []struct {
+		name            string
+		target          string
+		accept          string
+		wantStatus      int
+		wantContentType string
+	}{
+		{"csv list", "/v1/movies", "text/csv", http.StatusOK, "text/csv"},
+		{"csv object falls back", "/v1/movies/1", "text/csv, application/xml;q=0.5", http.StatusOK, "application/xml"},
+		{"csv object not acceptable", "/v1/movies/1", "text/csv", http.StatusNotAcceptable, "application/json"},
+		{"unknown type not acceptable", "/v1/movies/1", "image/png", http.StatusNotAcceptable, "application/json"},
+		{"json refused", "/v1/movies/1", "application/json;q=0, text/csv", http.StatusNotAcceptable, "application/json"},
+		{"errors fall back to json", "/v1/movies/2", "text/csv", http.StatusNotFound, "application/json"},
+		{"msgpack", "/v1/movies/1", "application/msgpack", http.StatusOK, "application/msgpack"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(t)
+			app.models.Movies = storedMovie{movie: movie}
+			rr := serve(t, app.routes(), http.MethodGet, tt.target, "", http.Header{"Accept": {tt.accept}})
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d; body %s", rr.Code, tt.wantStatus, rr.Body)
+			}
+			if got, _, _ := strings.Cut(rr.Header().Get("Content-Type"), ";"); got != tt.wantContentType {
+				t.Errorf("Content-Type = %q, want %q", got, tt.wantContentType)
+			}
+			if tt.wantStatus == http.StatusNotAcceptable {
+				var body struct {
+					Error string `json:"error"`
+				}
+				err := json.Unmarshal(rr.Body.Bytes(), &body)
+				if err != nil || body.Error != notAcceptableMessage {
+					t.Errorf("body = %s, want the not acceptable error", rr.Body)
+				}
+			}
+		})
+	}
+}
+
+func TestCSVListResponse(t *testing.T) {
+	app := newTestApplication(t)
+	app.models.Movies = storedMovie{movie: &data.Movie{ID: 1, Type: "movie", Title: "Moana, the film", Runtime: 107, Genres: []string{"animation"}, Version: 1}}
+	rr := serve(t, app.routes(), http.MethodGet, "/v1/movies", "", http.Header{"Accept": {"text/csv"}})
+	if rr.Code != http.StatusOK {
+		t.Fatalf("status = %d; body %s", rr.Code, rr.Body)
+	}
+	records, err := csv.NewReader(rr.Body).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][]string{
+		{"id", "genres", "runtime", "title", "type", "version"},
+		{"1", `["animation"]`, "107 mins", "Moana, the film", "movie", "1"},
+	}
+	if !reflect.DeepEqual(records, want) {
+		t.Errorf("rows = %q, want %q", records, want)
+	}
+	if got := rr.Header().Get("Envelope-Metadata"); got != "{}" {
+		t.Errorf("Envelope-Metadata = %q, want {}", got)
+	}
+}
+
+func TestMsgpackResponseMatchesJSON(t *testing.T) {
+	app := newTestApplication(t)
+	app.models.Movies = storedMovie{movie: &data.Movie{ID: 1, Type: "movie", Title: "Moana", Year: 2016, Runtime: 107, Genres: []string{"animation"}, Version: 3}}
+
+	js := serve(t, app.routes(), http.MethodGet, "/v1/movies/1", "", nil)
+	mp := serve(t, app.routes(), http.MethodGet, "/v1/movies/1", "", http.Header{"Accept": {"application/msgpack"}})
+	if js.Code != http.StatusOK || mp.Code != http.StatusOK {
+		t.Fatalf("status = %d and %d, want 200", js.Code, mp.Code)
+	}
+	var want map[string]any
+	err := json.Unmarshal(js.Body.Bytes(), &want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got map[string]any
+	decoder := msgpack.NewDecoder(mp.Body)
+	decoder.UseLooseInterfaceDecoding(true)
+	err = decoder.Decode(&got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Re-encode through JSON so integers and floats compare as float64.
+	roundTrip, err := json.Marshal(got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got = nil
+	err = json.Unmarshal(roundTrip, &got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("msgpack = %v, want %v", got, want)
+	}
+}
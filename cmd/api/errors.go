@@ -3,6 +3,16 @@ package main
 import (
 	"fmt"
 	"net/http"
+
+	"github.com/noonacedia/cinematrique/internal/data"
+)
+
+// Messages shared by the JSON responses below and the other APIs and batch
+// results that report the same failures.
+const (
+	serverErrorMessage  = "the server encountered a problem and could not process your request"
+	notFoundMessage     = "the request resource could not be found"
+	editConflictMessage = "unable to update the record due to an edit conflict, please try again"
 )
 
 func (app *application) logError(r *http.Request, err error) {
@@ -20,13 +30,11 @@ func (app *application) errorResponse(w http.ResponseWriter, r *http.Request, st
 
 func (app *application) serverErrorResponse(w http.ResponseWriter, r *http.Request, err error) {
 	app.logError(r, err)
-	msg := "the server encountered a problem and could not process your request"
-	app.errorResponse(w, r, http.StatusInternalServerError, msg)
+	app.errorResponse(w, r, http.StatusInternalServerError, serverErrorMessage)
 }
 
 func (app *application) notFoundResponse(w http.ResponseWriter, r *http.Request) {
-	msg := "the request resource could not be found"
-	app.errorResponse(w, r, http.StatusNotFound, msg)
+	app.errorResponse(w, r, http.StatusNotFound, notFoundMessage)
 }
 
 func (app *application) methodNotAllowedResponse(w http.ResponseWriter, r *http.Request) {
@@ -43,11 +51,46 @@ func (app *application) failedValidationResponse(w http.ResponseWriter, r *http.
 }
 
 func (app *application) editConflictResponse(w http.ResponseWriter, r *http.Request) {
-	msg := "unable to update the record due to an edit conflict, please try again"
-	app.errorResponse(w, r, http.StatusConflict, msg)
+	app.errorResponse(w, r, http.StatusConflict, editConflictMessage)
 }
 
 func (app *application) rateLimitExceedResponse(w http.ResponseWriter, r *http.Request) {
 	msg := "rate limit exceeded"
 	app.errorResponse(w, r, http.StatusTooManyRequests, msg)
 }
+
+func (app *application) duplicateExternalIDResponse(w http.ResponseWriter, r *http.Request, duplicate *data.DuplicateExternalIDError) {
+	msg := map[string]any{
+		"message": fmt.Sprintf("a movie with %s id %q already exists", duplicate.Source, duplicate.Value),
+	}
+	if duplicate.MovieID != 0 {
+		location := fmt.Sprintf("/v1/movies/%d", duplicate.MovieID)
+		w.Header().Set("Location", location)
+		msg["existing_movie"] = location
+	}
+	app.errorResponse(w, r, http.StatusConflict, msg)
+}
+
+func (app *application) duplicateMovieResponse(w http.ResponseWriter, r *http.Request, candidates []*data.Movie) {
+	msg := map[string]any{
+		"message":    "the movie looks like a duplicate of an existing record, retry with force=true to create it anyway",
+		"candidates": candidates,
+	}
+	app.errorResponse(w, r, http.StatusConflict, msg)
+}
+
+func (app *application) idempotencyKeyInFlightResponse(w http.ResponseWriter, r *http.Request) {
+	msg := "a request with this Idempotency-Key is still being processed, please retry later"
+	app.errorResponse(w, r, http.StatusConflict, msg)
+}
+
+func (app *application) idempotencyKeyMismatchResponse(w http.ResponseWriter, r *http.Request) {
+	msg := "the Idempotency-Key has already been used with a different request"
+	app.errorResponse(w, r, http.StatusUnprocessableEntity, msg)
+}
+
+const notAcceptableMessage = "the requested resource cannot be represented in any of the media types in the Accept header"
+
+func (app *application) notAcceptableResponse(w http.ResponseWriter, r *http.Request) {
+	app.errorResponse(w, r, http.StatusNotAcceptable, notAcceptableMessage)
+}
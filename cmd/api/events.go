@@ -0,0 +1,107 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/noonacedia/cinematrique/internal/data"
+	"github.com/noonacedia/cinematrique/internal/validator"
+)
+
+func (app *application) streamEventsHandler(w http.ResponseWriter, r *http.Request) {
+	v := validator.New()
+	qs := r.URL.Query()
+	types := app.readFieldList(qs, "events", data.EventTypes, v)
+	lastID := r.Header.Get("Last-Event-ID")
+	if lastID == "" {
+		lastID = app.readString(qs, "last_event_id", "0")
+	}
+	after, err := strconv.ParseInt(lastID, 10, 64)
+	v.Check(err == nil && after >= 0, "last_event_id", "must be a non-negative integer")
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	events := app.events.Subscribe(app.config.events.buffer)
+	defer app.events.Unsubscribe(events)
+
+	rc := http.NewResponseController(w)
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	send := func(event *data.OutboxEvent) error {
+		if event.Seq <= after {
+			return nil
+		}
+		after = event.Seq
+		if len(types) > 0 && !slices.Contains(types, event.Event) {
+			return nil
+		}
+		js, err := json.Marshal(event)
+		if err != nil {
+			return err
+		}
+		return app.writeEvent(w, rc, fmt.Sprintf("id: %d\nevent: %s\ndata: %s\n\n", event.Seq, event.Event, js))
+	}
+
+	if r.Header.Get("Last-Event-ID") != "" || qs.Has("last_event_id") {
+		for {
+			missed, err := app.models.Outbox.GetAfter(after, 500)
+			if err != nil {
+				app.logError(r, err)
+				return
+			}
+			for _, event := range missed {
+				if err := send(event); err != nil {
+					return
+				}
+			}
+			if len(missed) < 500 {
+				break
+			}
+		}
+	}
+	err = app.writeEvent(w, rc, "retry: 3000\n\n")
+	if err != nil {
+		return
+	}
+
+	heartbeat := time.NewTicker(app.config.events.heartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-heartbeat.C:
+			err = app.writeEvent(w, rc, ": heartbeat\n\n")
+		case event, ok := <-events:
+			if !ok {
+				app.writeEvent(w, rc, ": stream closed, reconnect with Last-Event-ID to resume\n\n")
+				return
+			}
+			err = send(event)
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+func (app *application) writeEvent(w http.ResponseWriter, rc *http.ResponseController, message string) error {
+	err := rc.SetWriteDeadline(time.Now().Add(app.config.events.writeTimeout))
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprint(w, message)
+	if err != nil {
+		return err
+	}
+	return rc.Flush()
+}
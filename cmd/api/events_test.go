@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/noonacedia/cinematrique/internal/data"
+)
+
+// publishedOutbox serves events in published order.
+type publishedOutbox struct {
+	data.MockOutboxModel
+	events []*data.OutboxEvent
+}
+
+func (m publishedOutbox) GetAfter(seq int64, limit int) ([]*data.OutboxEvent, error) {
+	var events []*data.OutboxEvent
+	for _, event := range m.events {
+		if event.Seq > seq && len(events) < limit {
+			events = append(events, event)
+		}
+	}
+	return events, nil
+}
+
+func TestStreamEventsResumesBySequence(t *testing.T) {
+	app := newTestApplication(t)
+	app.events = newEventHub()
+	app.config.events.buffer = 8
+	app.config.events.heartbeat = time.Minute
+	app.config.events.writeTimeout = time.Second
+	// Event 12 committed before event 11, so the relay published it first.
+	app.models.Outbox = publishedOutbox{events: []*data.OutboxEvent{
+		{ID: 10, Seq: 1, Event: "movie.created", Payload: []byte(`{}`)},
+		{ID: 12, Seq: 2, Event: "movie.created", Payload: []byte(`{}`)},
+		{ID: 11, Seq: 3, Event: "movie.updated", Payload: []byte(`{}`)},
+	}}
+	srv := httptest.NewServer(http.HandlerFunc(app.streamEventsHandler))
+	defer srv.Close()
+
+	req, err := http.NewRequest(http.MethodGet, srv.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Last-Event-ID", "1")
+	res, err := srv.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+
+	var ids []string
+	scanner := bufio.NewScanner(res.Body)
+	for scanner.Scan() && len(ids) < 2 {
+		if id, ok := strings.CutPrefix(scanner.Text(), "id: "); ok {
+			ids = append(ids, id)
+		}
+	}
+	if strings.Join(ids, ",") != "2,3" {
+		t.Fatalf("stream ids = %v, want [2 3]", ids)
+	}
+
+	// A live event already replayed from the outbox is not sent twice.
+	app.events.Publish(&data.OutboxEvent{ID: 11, Seq: 3, Event: "movie.updated", Payload: []byte(`{}`)})
+	app.events.Publish(&data.OutboxEvent{ID: 13, Seq: 4, Event: "movie.deleted", Payload: []byte(`{}`)})
+	for scanner.Scan() {
+		if id, ok := strings.CutPrefix(scanner.Text(), "id: "); ok {
+			if id != "4" {
+				t.Errorf("next live id = %s, want 4", id)
+			}
+			break
+		}
+	}
+}
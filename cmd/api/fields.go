@@ -0,0 +1,91 @@
+package main
+
+import (
+	"encoding/json"
+	"slices"
+
+	"github.com/noonacedia/cinematrique/internal/data"
+)
+
+type relationLoader func(movieIDs []int64) (map[int64]any, error)
+
+func (app *application) movieRelations() map[string]relationLoader {
+	return map[string]relationLoader{
+		"titles": func(movieIDs []int64) (map[int64]any, error) {
+			titles, err := app.models.MovieTitles.GetAllForMovies(movieIDs)
+			if err != nil {
+				return nil, err
+			}
+			related := make(map[int64]any, len(movieIDs))
+			for _, id := range movieIDs {
+				if movieTitles, ok := titles[id]; ok {
+					related[id] = movieTitles
+				} else {
+					related[id] = []*data.MovieTitle{}
+				}
+			}
+			return related, nil
+		},
+		"releases": func(movieIDs []int64) (map[int64]any, error) {
+			releases, err := app.models.Releases.GetAllForMovies(movieIDs)
+			if err != nil {
+				return nil, err
+			}
+			related := make(map[int64]any, len(movieIDs))
+			for _, id := range movieIDs {
+				if movieReleases, ok := releases[id]; ok {
+					related[id] = movieReleases
+				} else {
+					related[id] = []*data.Release{}
+				}
+			}
+			return related, nil
+		},
+	}
+}
+
+func (app *application) movieRelationNames() []string {
+	names := make([]string, 0)
+	for name := range app.movieRelations() {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// projectMovies keeps the given fields of each movie and adds the included
+// relations. The fields are already encoded, so runtimes are rendered in
+// format here rather than when the response is written.
+func (app *application) projectMovies(movies []*data.Movie, fields, include []string, format data.RuntimeFormat) ([]map[string]any, error) {
+	projected := make([]map[string]any, len(movies))
+	ids := make([]int64, len(movies))
+	for i, movie := range movies {
+		js, err := json.Marshal(data.FormatRuntimes(movie, format))
+		if err != nil {
+			return nil, err
+		}
+		var all map[string]json.RawMessage
+		err = json.Unmarshal(js, &all)
+		if err != nil {
+			return nil, err
+		}
+		projected[i] = make(map[string]any, len(all))
+		for key, value := range all {
+			if len(fields) == 0 || slices.Contains(fields, key) {
+				projected[i][key] = value
+			}
+		}
+		ids[i] = movie.ID
+	}
+	relations := app.movieRelations()
+	for _, name := range include {
+		related, err := relations[name](ids)
+		if err != nil {
+			return nil, err
+		}
+		for i, movie := range movies {
+			projected[i][name] = related[movie.ID]
+		}
+	}
+	return projected, nil
+}
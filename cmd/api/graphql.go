@@ -0,0 +1,780 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/graphql-go/graphql/language/parser"
+	"github.com/noonacedia/cinematrique/internal/data"
+	"github.com/noonacedia/cinematrique/internal/validator"
+)
+
+type graphqlError struct {
+	message    string
+	extensions map[string]any
+}
+
+func (e *graphqlError) Error() string {
+	return e.message
+}
+
+func (e *graphqlError) Extensions() map[string]any {
+	return e.extensions
+}
+
+func (app *application) graphqlFailure(err error) error {
+	var duplicate *data.DuplicateExternalIDError
+	switch {
+	case errors.Is(err, data.ErrRecordNotFound):
+		return &graphqlError{"the requested resource could not be found", map[string]any{"code": "NOT_FOUND"}}
+	case errors.Is(err, data.ErrEditConflict):
+		return &graphqlError{editConflictMessage, map[string]any{"code": "EDIT_CONFLICT"}}
+	case errors.As(err, &duplicate):
+		return &graphqlError{fmt.Sprintf("a movie with %s id %q already exists", duplicate.Source, duplicate.Value), map[string]any{
+			"code":           "CONFLICT",
+			"existing_movie": duplicate.MovieID,
+		}}
+	case errors.Is(err, data.ErrDuplicateRelease):
+		return graphqlValidationError(map[string]string{"releases": "must not contain more than one release per country and type"})
+	case errors.Is(err, data.ErrInvalidCursor):
+		return graphqlValidationError(map[string]string{"cursor": "must be a valid cursor for the requested sort"})
+	default:
+		app.logger.PrintError(err, map[string]string{"request_url": "/v1/graphql"})
+		return &graphqlError{serverErrorMessage, map[string]any{"code": "INTERNAL"}}
+	}
+}
+
+func graphqlValidationError(errors map[string]string) error {
+	return &graphqlError{"failed validation", map[string]any{"code": "VALIDATION", "errors": errors}}
+}
+
+type batchLoader struct {
+	mu      sync.Mutex
+	fetch   relationLoader
+	pending []int64
+	results map[int64]any
+}
+
+func newBatchLoader(fetch relationLoader) *batchLoader {
+	return &batchLoader{fetch: fetch, results: make(map[int64]any)}
+}
+
+func (l *batchLoader) load(id int64) func() (any, error) {
+	l.mu.Lock()
+	if _, ok := l.results[id]; !ok && !slices.Contains(l.pending, id) {
+		l.pending = append(l.pending, id)
+	}
+	l.mu.Unlock()
+	return func() (any, error) {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		if len(l.pending) > 0 {
+			ids := l.pending
+			l.pending = nil
+			related, err := l.fetch(ids)
+			if err != nil {
+				return nil, err
+			}
+			for _, id := range ids {
+				l.results[id] = related[id]
+			}
+		}
+		return l.results[id], nil
+	}
+}
+
+type graphqlLoaders map[string]*batchLoader
+
+type graphqlLoadersKey struct{}
+
+func (app *application) newGraphQLLoaders() graphqlLoaders {
+	loaders := make(graphqlLoaders)
+	for name, fetch := range app.movieRelations() {
+		loaders[name] = newBatchLoader(fetch)
+	}
+	loaders["images"] = newBatchLoader(func(movieIDs []int64) (map[int64]any, error) {
+		images, err := app.models.MovieImages.GetAllForMovies(movieIDs)
+		if err != nil {
+			return nil, err
+		}
+		related := make(map[int64]any, len(movieIDs))
+		for _, id := range movieIDs {
+			for _, img := range images[id] {
+				app.setImageURLs(img)
+			}
+			related[id] = images[id]
+		}
+		return related, nil
+	})
+	loaders["collection"] = newBatchLoader(func(movieIDs []int64) (map[int64]any, error) {
+		summaries, err := app.models.Collections.GetSummariesForMovies(movieIDs)
+		if err != nil {
+			return nil, err
+		}
+		related := make(map[int64]any, len(movieIDs))
+		for _, id := range movieIDs {
+			if summary, ok := summaries[id]; ok {
+				related[id] = summary
+			}
+		}
+		return related, nil
+	})
+	return loaders
+}
+
+func (app *application) movieRelationResolver(name string) graphql.FieldResolveFn {
+	return func(p graphql.ResolveParams) (any, error) {
+		movie := p.Source.(*data.Movie)
+		loaders := p.Context.Value(graphqlLoadersKey{}).(graphqlLoaders)
+		thunk := loaders[name].load(movie.ID)
+		return func() (any, error) {
+			related, err := thunk()
+			if err != nil {
+				return nil, app.graphqlFailure(err)
+			}
+			return related, nil
+		}, nil
+	}
+}
+
+var runtimeScalar = graphql.NewScalar(graphql.ScalarConfig{
+	Name:        "Runtime",
+	Description: `A movie runtime, e.g. "102 mins", "1h 42m" or "PT1H42M".`,
+	Serialize: func(value any) any {
+		if runtime, ok := value.(data.Runtime); ok && runtime > 0 {
+			return runtime.Format(data.RuntimeFormatMins)
+		}
+		return nil
+	},
+	ParseValue: func(value any) any {
+		switch value := value.(type) {
+		case string:
+			if runtime, err := data.ParseRuntime(value); err == nil {
+				return runtime
+			}
+		case int:
+			return data.Runtime(value)
+		case float64:
+			return data.Runtime(value)
+		}
+		return nil
+	},
+	ParseLiteral: func(value ast.Value) any {
+		switch value := value.(type) {
+		case *ast.StringValue:
+			if runtime, err := data.ParseRuntime(value.Value); err == nil {
+				return runtime
+			}
+		case *ast.IntValue:
+			if n, err := strconv.ParseInt(value.Value, 10, 32); err == nil {
+				return data.Runtime(n)
+			}
+		}
+		return nil
+	},
+})
+
+var dateScalar = graphql.NewScalar(graphql.ScalarConfig{
+	Name:        "Date",
+	Description: "A calendar date in YYYY-MM-DD format.",
+	Serialize: func(value any) any {
+		if date, ok := value.(data.Date); ok {
+			return date.Format(time.DateOnly)
+		}
+		return nil
+	},
+	ParseValue: func(value any) any {
+		if s, ok := value.(string); ok {
+			if t, err := time.Parse(time.DateOnly, s); err == nil {
+				return data.Date{Time: t}
+			}
+		}
+		return nil
+	},
+	ParseLiteral: func(value ast.Value) any {
+		if s, ok := value.(*ast.StringValue); ok {
+			if t, err := time.Parse(time.DateOnly, s.Value); err == nil {
+				return data.Date{Time: t}
+			}
+		}
+		return nil
+	},
+})
+
+type externalID struct {
+	Source string `json:"source"`
+	Value  string `json:"value"`
+}
+
+func (app *application) newGraphQLSchema() (graphql.Schema, error) {
+	externalIDType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "ExternalID",
+		Fields: graphql.Fields{
+			"source": &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"value":  &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+		},
+	})
+	titleType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "MovieTitle",
+		Fields: graphql.Fields{
+			"id":     &graphql.Field{Type: graphql.NewNonNull(graphql.ID)},
+			"locale": &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"kind":   &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"title":  &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+		},
+	})
+	releaseType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Release",
+		Fields: graphql.Fields{
+			"id":            &graphql.Field{Type: graphql.NewNonNull(graphql.ID)},
+			"country":       &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"date":          &graphql.Field{Type: graphql.NewNonNull(dateScalar)},
+			"type":          &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"certification": &graphql.Field{Type: graphql.String},
+		},
+	})
+	thumbnailType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Thumbnail",
+		Fields: graphql.Fields{
+			"url":    &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"width":  &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+			"height": &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+		},
+	})
+	imageType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "MovieImage",
+		Fields: graphql.Fields{
+			"id":          &graphql.Field{Type: graphql.NewNonNull(graphql.ID)},
+			"kind":        &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"contentType": &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"url":         &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"width":       &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+			"height":      &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+			"size":        &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+			"thumbnail":   &graphql.Field{Type: thumbnailType},
+		},
+	})
+	collectionType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "CollectionSummary",
+		Fields: graphql.Fields{
+			"id":       &graphql.Field{Type: graphql.NewNonNull(graphql.ID)},
+			"name":     &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"position": &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+			"size":     &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+		},
+	})
+	movieType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Movie",
+		Fields: graphql.Fields{
+			"id":            &graphql.Field{Type: graphql.NewNonNull(graphql.ID)},
+			"type":          &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"title":         &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"originalTitle": &graphql.Field{Type: graphql.String},
+			"year":          &graphql.Field{Type: graphql.Int},
+			"runtime":       &graphql.Field{Type: runtimeScalar},
+			"genres":        &graphql.Field{Type: graphql.NewList(graphql.NewNonNull(graphql.String))},
+			"version":       &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+			"externalIds": &graphql.Field{
+				Type: graphql.NewList(graphql.NewNonNull(externalIDType)),
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					movie := p.Source.(*data.Movie)
+					ids := make([]externalID, 0, len(movie.ExternalIDs))
+					for source, value := range movie.ExternalIDs {
+						ids = append(ids, externalID{source, value})
+					}
+					slices.SortFunc(ids, func(a, b externalID) int {
+						return strings.Compare(a.Source, b.Source)
+					})
+					return ids, nil
+				},
+			},
+			"titles":     &graphql.Field{Type: graphql.NewList(graphql.NewNonNull(titleType)), Resolve: app.movieRelationResolver("titles")},
+			"releases":   &graphql.Field{Type: graphql.NewList(graphql.NewNonNull(releaseType)), Resolve: app.movieRelationResolver("releases")},
+			"images":     &graphql.Field{Type: graphql.NewList(graphql.NewNonNull(imageType)), Resolve: app.movieRelationResolver("images")},
+			"collection": &graphql.Field{Type: collectionType, Resolve: app.movieRelationResolver("collection")},
+		},
+	})
+	metadataType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Metadata",
+		Fields: graphql.Fields{
+			"currentPage":  &graphql.Field{Type: graphql.Int},
+			"pageSize":     &graphql.Field{Type: graphql.Int},
+			"firstPage":    &graphql.Field{Type: graphql.Int},
+			"lastPage":     &graphql.Field{Type: graphql.Int},
+			"totalRecords": &graphql.Field{Type: graphql.Int},
+			"nextCursor":   &graphql.Field{Type: graphql.String},
+			"prevCursor":   &graphql.Field{Type: graphql.String},
+		},
+	})
+	moviePageType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "MoviePage",
+		Fields: graphql.Fields{
+			"movies":   &graphql.Field{Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(movieType)))},
+			"metadata": &graphql.Field{Type: graphql.NewNonNull(metadataType)},
+		},
+	})
+
+	userType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "User",
+		Fields: graphql.Fields{
+			"id":        &graphql.Field{Type: graphql.NewNonNull(graphql.ID)},
+			"name":      &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"email":     &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"activated": &graphql.Field{Type: graphql.NewNonNull(graphql.Boolean)},
+			"createdAt": &graphql.Field{Type: graphql.NewNonNull(graphql.DateTime), Resolve: func(p graphql.ResolveParams) (any, error) {
+				return p.Source.(*data.User).CreatedAt, nil
+			}},
+		},
+	})
+
+	externalIDInputType := graphql.NewInputObject(graphql.InputObjectConfig{
+		Name: "ExternalIDInput",
+		Fields: graphql.InputObjectConfigFieldMap{
+			"source": &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.String)},
+			"value":  &graphql.InputObjectFieldConfig{Type: graphql.String, Description: "Null removes the external id on update."},
+		},
+	})
+	releaseInputType := graphql.NewInputObject(graphql.InputObjectConfig{
+		Name: "ReleaseInput",
+		Fields: graphql.InputObjectConfigFieldMap{
+			"country":       &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.String)},
+			"date":          &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(dateScalar)},
+			"type":          &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.String)},
+			"certification": &graphql.InputObjectFieldConfig{Type: graphql.String},
+		},
+	})
+	movieInputType := graphql.NewInputObject(graphql.InputObjectConfig{
+		Name: "MovieInput",
+		Fields: graphql.InputObjectConfigFieldMap{
+			"type":        &graphql.InputObjectFieldConfig{Type: graphql.String},
+			"title":       &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.String)},
+			"year":        &graphql.InputObjectFieldConfig{Type: graphql.Int},
+			"runtime":     &graphql.InputObjectFieldConfig{Type: runtimeScalar},
+			"genres":      &graphql.InputObjectFieldConfig{Type: graphql.NewList(graphql.NewNonNull(graphql.String))},
+			"externalIds": &graphql.InputObjectFieldConfig{Type: graphql.NewList(graphql.NewNonNull(externalIDInputType))},
+			"releases":    &graphql.InputObjectFieldConfig{Type: graphql.NewList(graphql.NewNonNull(releaseInputType))},
+		},
+	})
+	moviePatchType := graphql.NewInputObject(graphql.InputObjectConfig{
+		Name: "MoviePatch",
+		Fields: graphql.InputObjectConfigFieldMap{
+			"title":       &graphql.InputObjectFieldConfig{Type: graphql.String},
+			"year":        &graphql.InputObjectFieldConfig{Type: graphql.Int},
+			"runtime":     &graphql.InputObjectFieldConfig{Type: runtimeScalar},
+			"genres":      &graphql.InputObjectFieldConfig{Type: graphql.NewList(graphql.NewNonNull(graphql.String))},
+			"externalIds": &graphql.InputObjectFieldConfig{Type: graphql.NewList(graphql.NewNonNull(externalIDInputType))},
+		},
+	})
+
+	query := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Query",
+		Fields: graphql.Fields{
+			"movie": &graphql.Field{
+				Type: movieType,
+				Args: graphql.FieldConfigArgument{
+					"id": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.ID)},
+				},
+				Resolve: app.resolveMovie,
+			},
+			"movies": &graphql.Field{
+				Type: graphql.NewNonNull(moviePageType),
+				Args: graphql.FieldConfigArgument{
+					"title":          &graphql.ArgumentConfig{Type: graphql.String},
+					"type":           &graphql.ArgumentConfig{Type: graphql.String},
+					"genres":         &graphql.ArgumentConfig{Type: graphql.NewList(graphql.NewNonNull(graphql.String))},
+					"releasedIn":     &graphql.ArgumentConfig{Type: graphql.String},
+					"releasedAfter":  &graphql.ArgumentConfig{Type: dateScalar},
+					"releasedBefore": &graphql.ArgumentConfig{Type: dateScalar},
+					"page":           &graphql.ArgumentConfig{Type: graphql.Int, DefaultValue: 1},
+					"pageSize":       &graphql.ArgumentConfig{Type: graphql.Int, DefaultValue: 20},
+					"sort":           &graphql.ArgumentConfig{Type: graphql.String},
+					"cursor":         &graphql.ArgumentConfig{Type: graphql.String},
+					"includeTotal":   &graphql.ArgumentConfig{Type: graphql.Boolean, DefaultValue: true},
+				},
+				Resolve: app.resolveMovies,
+			},
+			"me": &graphql.Field{
+				Type:        userType,
+				Description: "The authenticated user. Always null until requests can be authenticated.",
+				Resolve:     app.resolveMe,
+			},
+		},
+	})
+	mutation := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Mutation",
+		Fields: graphql.Fields{
+			"createMovie": &graphql.Field{
+				Type: graphql.NewNonNull(movieType),
+				Args: graphql.FieldConfigArgument{
+					"input": &graphql.ArgumentConfig{Type: graphql.NewNonNull(movieInputType)},
+					"force": &graphql.ArgumentConfig{Type: graphql.Boolean, DefaultValue: false},
+				},
+				Resolve: app.resolveCreateMovie,
+			},
+			"updateMovie": &graphql.Field{
+				Type: graphql.NewNonNull(movieType),
+				Args: graphql.FieldConfigArgument{
+					"id":      &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.ID)},
+					"version": &graphql.ArgumentConfig{Type: graphql.Int},
+					"input":   &graphql.ArgumentConfig{Type: graphql.NewNonNull(moviePatchType)},
+				},
+				Resolve: app.resolveUpdateMovie,
+			},
+			"deleteMovie": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.Boolean),
+				Args: graphql.FieldConfigArgument{
+					"id": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.ID)},
+				},
+				Resolve: app.resolveDeleteMovie,
+			},
+		},
+	})
+	return graphql.NewSchema(graphql.SchemaConfig{Query: query, Mutation: mutation})
+}
+
+func graphqlStrings(value any) []string {
+	items, _ := value.([]any)
+	strs := make([]string, 0, len(items))
+	for _, item := range items {
+		if s, ok := item.(string); ok {
+			strs = append(strs, s)
+		}
+	}
+	return strs
+}
+
+func graphqlExternalIDs(value any) map[string]*string {
+	items, ok := value.([]any)
+	if !ok {
+		return nil
+	}
+	ids := make(map[string]*string, len(items))
+	for _, item := range items {
+		fields := item.(map[string]any)
+		var value *string
+		if s, ok := fields["value"].(string); ok {
+			value = &s
+		}
+		ids[fields["source"].(string)] = value
+	}
+	return ids
+}
+
+func (app *application) resolveMovie(p graphql.ResolveParams) (any, error) {
+	id, err := strconv.ParseInt(p.Args["id"].(string), 10, 64)
+	if err != nil {
+		return nil, nil
+	}
+	movie, err := app.models.Movies.Get(id)
+	if err != nil {
+		if errors.Is(err, data.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, app.graphqlFailure(err)
+	}
+	return movie, nil
+}
+
+// resolveMe only ever exposes the caller's own account. No request carries an
+// authenticated user yet, so every caller is anonymous.
+func (app *application) resolveMe(p graphql.ResolveParams) (any, error) {
+	return nil, nil
+}
+
+func (app *application) resolveMovies(p graphql.ResolveParams) (any, error) {
+	var search data.MovieSearch
+	var filters data.Filters
+	search.Title, _ = p.Args["title"].(string)
+	search.Type, _ = p.Args["type"].(string)
+	search.Genres = graphqlStrings(p.Args["genres"])
+	releasedIn, _ := p.Args["releasedIn"].(string)
+	search.ReleasedIn = strings.ToUpper(releasedIn)
+	if date, ok := p.Args["releasedAfter"].(data.Date); ok {
+		search.ReleasedAfter = date.Time
+	}
+	if date, ok := p.Args["releasedBefore"].(data.Date); ok {
+		search.ReleasedBefore = date.Time
+	}
+	filters.Page = p.Args["page"].(int)
+	filters.PageSize = p.Args["pageSize"].(int)
+	filters.Sort = "id"
+	if search.Title != "" {
+		filters.Sort = "relevance"
+	}
+	if sort, ok := p.Args["sort"].(string); ok {
+		filters.Sort = sort
+	}
+	filters.SortSafelist = []string{"id", "-id", "title", "-title", "year", "-year", "runtime", "-runtime", "relevance"}
+	filters.Cursor, filters.UseCursor = p.Args["cursor"].(string)
+	filters.SkipTotal = !p.Args["includeTotal"].(bool)
+
+	v := validator.New()
+	data.ValidateFilters(v, filters)
+	v.Check(filters.Sort != "relevance" || search.Title != "", "sort", "relevance is only valid with a title query")
+	v.Check(search.ReleasedIn == "" || validator.Matches(search.ReleasedIn, data.CountryRx), "releasedIn", "must be an ISO 3166-1 alpha-2 country code")
+	v.Check(search.Type == "" || validator.In(search.Type, data.MovieTypes...), "type", "must be one of: "+strings.Join(data.MovieTypes, ", "))
+	if !v.Valid() {
+		return nil, graphqlValidationError(v.Errors)
+	}
+	movies, metadata, err := app.models.Movies.GetAll(search, filters)
+	if err != nil {
+		return nil, app.graphqlFailure(err)
+	}
+	return map[string]any{"movies": movies, "metadata": metadata}, nil
+}
+
+func (app *application) resolveCreateMovie(p graphql.ResolveParams) (any, error) {
+	args := p.Args["input"].(map[string]any)
+	input := movieInput{Genres: graphqlStrings(args["genres"])}
+	input.Type, _ = args["type"].(string)
+	input.Title, _ = args["title"].(string)
+	if year, ok := args["year"].(int); ok {
+		input.Year = int32(year)
+	}
+	input.Runtime, _ = args["runtime"].(data.Runtime)
+	if ids := graphqlExternalIDs(args["externalIds"]); ids != nil {
+		input.ExternalIDs = make(map[string]string, len(ids))
+		for source, value := range ids {
+			if value != nil {
+				input.ExternalIDs[source] = *value
+			}
+		}
+	}
+	releases, _ := args["releases"].([]any)
+	for _, item := range releases {
+		fields := item.(map[string]any)
+		release := &data.Release{
+			Country: fields["country"].(string),
+			Date:    fields["date"].(data.Date),
+			Type:    fields["type"].(string),
+		}
+		release.Certification, _ = fields["certification"].(string)
+		input.Releases = append(input.Releases, release)
+	}
+	movie := input.movie()
+
+	v := validator.New()
+	if data.ValidateMovie(v, movie); !v.Valid() {
+		return nil, graphqlValidationError(v.Errors)
+	}
+	if !p.Args["force"].(bool) {
+		candidates, err := app.models.Movies.FindDuplicates(movie.Title, movie.Year)
+		if err != nil {
+			return nil, app.graphqlFailure(err)
+		}
+		if len(candidates) > 0 {
+			ids := make([]int64, len(candidates))
+			for i, candidate := range candidates {
+				ids[i] = candidate.ID
+			}
+			return nil, &graphqlError{"the movie looks like a duplicate of an existing record, retry with force: true to create it anyway", map[string]any{
+				"code":       "DUPLICATE",
+				"candidates": ids,
+			}}
+		}
+	}
+	err := app.models.Movies.Insert(movie)
+	if err != nil {
+		return nil, app.graphqlFailure(err)
+	}
+	return movie, nil
+}
+
+func (app *application) resolveUpdateMovie(p graphql.ResolveParams) (any, error) {
+	id, err := strconv.ParseInt(p.Args["id"].(string), 10, 64)
+	if err != nil {
+		return nil, app.graphqlFailure(data.ErrRecordNotFound)
+	}
+	movie, err := app.models.Movies.Get(id)
+	if err != nil {
+		return nil, app.graphqlFailure(err)
+	}
+	if version, ok := p.Args["version"].(int); ok && int32(version) != movie.Version {
+		return nil, app.graphqlFailure(data.ErrEditConflict)
+	}
+	args := p.Args["input"].(map[string]any)
+	var input moviePatch
+	if title, ok := args["title"].(string); ok {
+		input.Title = &title
+	}
+	if year, ok := args["year"].(int); ok {
+		year := int32(year)
+		input.Year = &year
+	}
+	if runtime, ok := args["runtime"].(data.Runtime); ok {
+		input.Runtime = &runtime
+	}
+	if _, ok := args["genres"]; ok {
+		input.Genres = graphqlStrings(args["genres"])
+	}
+	input.ExternalIDs = graphqlExternalIDs(args["externalIds"])
+	input.apply(movie)
+
+	v := validator.New()
+	if data.ValidateMovie(v, movie); !v.Valid() {
+		return nil, graphqlValidationError(v.Errors)
+	}
+	err = app.models.Movies.Update(movie)
+	if err != nil {
+		return nil, app.graphqlFailure(err)
+	}
+	return movie, nil
+}
+
+func (app *application) resolveDeleteMovie(p graphql.ResolveParams) (any, error) {
+	id, err := strconv.ParseInt(p.Args["id"].(string), 10, 64)
+	if err != nil {
+		return nil, app.graphqlFailure(data.ErrRecordNotFound)
+	}
+	err = app.deleteMovie(id)
+	if err != nil {
+		return nil, app.graphqlFailure(err)
+	}
+	return true, nil
+}
+
+type graphqlCost struct {
+	fragments map[string]*ast.FragmentDefinition
+	variables map[string]any
+	visiting  map[string]bool
+}
+
+func (c *graphqlCost) pageSize(field *ast.Field) int {
+	for _, arg := range field.Arguments {
+		if arg.Name.Value != "pageSize" {
+			continue
+		}
+		switch value := arg.Value.(type) {
+		case *ast.IntValue:
+			if n, err := strconv.Atoi(value.Value); err == nil {
+				return n
+			}
+		case *ast.Variable:
+			switch n := c.variables[value.Name.Value].(type) {
+			case float64:
+				return int(n)
+			case int:
+				return n
+			}
+		}
+	}
+	return 20
+}
+
+func (c *graphqlCost) measure(set *ast.SelectionSet, root bool) (depth, complexity int) {
+	if set == nil {
+		return 0, 0
+	}
+	for _, selection := range set.Selections {
+		var d, n int
+		switch selection := selection.(type) {
+		case *ast.Field:
+			d, n = c.measure(selection.SelectionSet, false)
+			if root && selection.Name.Value == "movies" {
+				n *= max(c.pageSize(selection), 1)
+			}
+			d, n = d+1, n+1
+		case *ast.InlineFragment:
+			d, n = c.measure(selection.SelectionSet, root)
+		case *ast.FragmentSpread:
+			name := selection.Name.Value
+			fragment, ok := c.fragments[name]
+			if !ok || c.visiting[name] {
+				continue
+			}
+			c.visiting[name] = true
+			d, n = c.measure(fragment.SelectionSet, root)
+			c.visiting[name] = false
+		}
+		depth = max(depth, d)
+		complexity += n
+	}
+	return depth, complexity
+}
+
+func (app *application) checkGraphQLLimits(doc *ast.Document, operationName string, variables map[string]any) *graphqlError {
+	cost := &graphqlCost{
+		fragments: make(map[string]*ast.FragmentDefinition),
+		variables: variables,
+		visiting:  make(map[string]bool),
+	}
+	var operations []*ast.OperationDefinition
+	for _, definition := range doc.Definitions {
+		switch definition := definition.(type) {
+		case *ast.FragmentDefinition:
+			cost.fragments[definition.Name.Value] = definition
+		case *ast.OperationDefinition:
+			if operationName == "" || (definition.Name != nil && definition.Name.Value == operationName) {
+				operations = append(operations, definition)
+			}
+		}
+	}
+	for _, operation := range operations {
+		depth, complexity := cost.measure(operation.SelectionSet, true)
+		if depth > app.config.graphql.maxDepth {
+			return &graphqlError{fmt.Sprintf("query depth %d exceeds the maximum of %d", depth, app.config.graphql.maxDepth), map[string]any{"code": "QUERY_TOO_DEEP"}}
+		}
+		if complexity > app.config.graphql.maxComplexity {
+			return &graphqlError{fmt.Sprintf("query complexity %d exceeds the maximum of %d", complexity, app.config.graphql.maxComplexity), map[string]any{"code": "QUERY_TOO_COMPLEX"}}
+		}
+	}
+	return nil
+}
+
+func (app *application) graphqlHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Query         string         `json:"query"`
+		OperationName string         `json:"operationName"`
+		Variables     map[string]any `json:"variables"`
+		Extensions    map[string]any `json:"extensions"`
+	}
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	v := validator.New()
+	v.Check(strings.TrimSpace(input.Query) != "", "query", "must be provided")
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	doc, err := parser.Parse(parser.ParseParams{Source: input.Query})
+	if err == nil {
+		limitErr := app.checkGraphQLLimits(doc, input.OperationName, input.Variables)
+		if limitErr != nil {
+			err = app.writeJSON(w, http.StatusOK, envelope{"errors": []envelope{{"message": limitErr.message, "extensions": limitErr.extensions}}}, nil)
+			if err != nil {
+				app.serverErrorResponse(w, r, err)
+			}
+			return
+		}
+	}
+
+	ctx := context.WithValue(r.Context(), graphqlLoadersKey{}, app.newGraphQLLoaders())
+	result := graphql.Do(graphql.Params{
+		Schema:         app.graphql,
+		RequestString:  input.Query,
+		VariableValues: input.Variables,
+		OperationName:  input.OperationName,
+		Context:        ctx,
+	})
+	env := envelope{"data": result.Data}
+	if result.HasErrors() {
+		env["errors"] = result.Errors
+	}
+	err = app.writeJSON(w, http.StatusOK, env, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
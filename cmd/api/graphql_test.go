@@ -0,0 +1,185 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/noonacedia/cinematrique/internal/data"
+)
+
+func newGraphQLTestApplication(t *testing.T) *application {
+	t.Helper()
+	app := newTestApplication(t)
+	app.config.graphql.maxDepth = 8
+	app.config.graphql.maxComplexity = 5_000
+	var err error
+	app.graphql, err = app.newGraphQLSchema()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return app
+}
+
+type graphqlResult struct {
+	Data   map[string]json.RawMessage `json:"data"`
+	Errors []struct {
+		Message    string         `json:"message"`
+		Extensions map[string]any `json:"extensions"`
+	} `json:"errors"`
+}
+
+func postGraphQL(t *testing.T, app *application, query string) graphqlResult {
+	t.Helper()
+	body, err := json.Marshal(map[string]string{"query": query})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr := serve(t, http.HandlerFunc(app.graphqlHandler), http.MethodPost, "/v1/graphql", string(body), nil)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d; body %s", rr.Code, http.StatusOK, rr.Body)
+	}
+	var result graphqlResult
+	err = json.Unmarshal(rr.Body.Bytes(), &result)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return result
+}
+
+func TestGraphQLLimits(t *testing.T) {
+	tests := []struct {
+		name          string
+		maxDepth      int
+		maxComplexity int
+		query         string
+		wantCode      string
+	}{
+		{"too deep", 2, 5_000, `{ movie(id: "1") { images { thumbnail { url } } } }`, "QUERY_TOO_DEEP"},
+		{"too deep via fragment", 2, 5_000, `{ movie(id: "1") { ...M } } fragment M on Movie { collection { name } }`, "QUERY_TOO_DEEP"},
+		{"too complex", 8, 100, `{ movies(pageSize: 50) { movies { id title } } }`, "QUERY_TOO_COMPLEX"},
+		{"within limits", 8, 5_000, `{ movies(pageSize: 10) { movies { id title } } }`, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newGraphQLTestApplication(t)
+			app.config.graphql.maxDepth = tt.maxDepth
+			app.config.graphql.maxComplexity = tt.maxComplexity
+
+			result := postGraphQL(t, app, tt.query)
+			if tt.wantCode == "" {
+				if len(result.Errors) > 0 {
+					t.Fatalf("errors = %+v, want none", result.Errors)
+				}
+				return
+			}
+			if len(result.Errors) != 1 {
+				t.Fatalf("errors = %+v, want one", result.Errors)
+			}
+			if code := result.Errors[0].Extensions["code"]; code != tt.wantCode {
+				t.Errorf("code = %v, want %s", code, tt.wantCode)
+			}
+			if result.Data != nil {
+				t.Errorf("data = %v, want none for a rejected query", result.Data)
+			}
+		})
+	}
+}
+
+// pagedMovies returns a fixed page of movies.
+type pagedMovies struct {
+	data.MockMovieModel
+	movies []*data.Movie
+}
+
+func (m pagedMovies) GetAll(search data.MovieSearch, filters data.Filters) ([]*data.Movie, data.Metadata, error) {
+	return m.movies, data.Metadata{}, nil
+}
+
+// countingTitles records every batch of movie ids it is asked for.
+type countingTitles struct {
+	data.MockMovieTitleModel
+	calls *atomic.Int32
+	ids   *[]int64
+}
+
+func (m countingTitles) GetAllForMovies(movieIDs []int64) (map[int64][]*data.MovieTitle, error) {
+	m.calls.Add(1)
+	*m.ids = append(*m.ids, movieIDs...)
+	titles := make(map[int64][]*data.MovieTitle, len(movieIDs))
+	for _, id := range movieIDs {
+		titles[id] = []*data.MovieTitle{{ID: id * 10, MovieID: id, Locale: "en", Kind: "alternative", Title: "Title"}}
+	}
+	return titles, nil
+}
+
+type countingReleases struct {
+	data.MockReleaseModel
+	calls *atomic.Int32
+}
+
+func (m countingReleases) GetAllForMovies(movieIDs []int64) (map[int64][]*data.Release, error) {
+	m.calls.Add(1)
+	return nil, nil
+}
+
+func TestGraphQLBatchesRelations(t *testing.T) {
+	app := newGraphQLTestApplication(t)
+	movies := make([]*data.Movie, 5)
+	for i := range movies {
+		movies[i] = &data.Movie{ID: int64(i + 1), Title: "Movie", Type: "movie"}
+	}
+	app.models.Movies = pagedMovies{movies: movies}
+	var titleCalls, releaseCalls atomic.Int32
+	var titleIDs []int64
+	app.models.MovieTitles = countingTitles{calls: &titleCalls, ids: &titleIDs}
+	app.models.Releases = countingReleases{calls: &releaseCalls}
+
+	result := postGraphQL(t, app, `{ movies { movies { id titles { title } releases { country } } } }`)
+	if len(result.Errors) > 0 {
+		t.Fatalf("errors = %+v", result.Errors)
+	}
+	if n := titleCalls.Load(); n != 1 {
+		t.Errorf("titles fetched %d times, want 1", n)
+	}
+	if n := releaseCalls.Load(); n != 1 {
+		t.Errorf("releases fetched %d times, want 1", n)
+	}
+	if len(titleIDs) != len(movies) {
+		t.Errorf("titles fetched for %v, want all %d movies", titleIDs, len(movies))
+	}
+
+	var page struct {
+		Movies []struct {
+			Titles []struct {
+				Title string `json:"title"`
+			} `json:"titles"`
+			Releases []any `json:"releases"`
+		} `json:"movies"`
+	}
+	err := json.Unmarshal(result.Data["movies"], &page)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, movie := range page.Movies {
+		if len(movie.Titles) != 1 {
+			t.Errorf("movie %d titles = %v, want one", i, movie.Titles)
+		}
+		if movie.Releases == nil {
+			t.Errorf("movie %d releases = null, want []", i)
+		}
+	}
+}
+
+func TestGraphQLMeIsAnonymous(t *testing.T) {
+	app := newGraphQLTestApplication(t)
+	result := postGraphQL(t, app, `{ me { id email } }`)
+	if len(result.Errors) > 0 {
+		t.Fatalf("errors = %+v", result.Errors)
+	}
+	if me := strings.TrimSpace(string(result.Data["me"])); me != "null" {
+		t.Errorf("me = %s, want null", me)
+	}
+}
@@ -0,0 +1,281 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"slices"
+	"strings"
+
+	"github.com/noonacedia/cinematrique/internal/data"
+	"github.com/noonacedia/cinematrique/internal/validator"
+	"github.com/noonacedia/cinematrique/rpc/moviesv1"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+var movieUpdateMaskPaths = []string{"title", "year", "runtime", "genres", "external_ids"}
+
+func toRPCMovie(movie *data.Movie) *moviesv1.Movie {
+	return &moviesv1.Movie{
+		Id:          movie.ID,
+		Type:        movie.Type,
+		Title:       movie.Title,
+		Year:        movie.Year,
+		Runtime:     int32(movie.Runtime),
+		Genres:      movie.Genres,
+		Version:     movie.Version,
+		ExternalIds: movie.ExternalIDs,
+	}
+}
+
+func (app *application) grpcError(err error) error {
+	var duplicate *data.DuplicateExternalIDError
+	switch {
+	case errors.Is(err, data.ErrRecordNotFound):
+		return status.Error(codes.NotFound, "the requested resource could not be found")
+	case errors.Is(err, data.ErrEditConflict):
+		return status.Error(codes.Aborted, editConflictMessage)
+	case errors.As(err, &duplicate):
+		return status.Errorf(codes.AlreadyExists, "a movie with %s id %q already exists", duplicate.Source, duplicate.Value)
+	case errors.Is(err, data.ErrDuplicateRelease):
+		return grpcValidationError(map[string]string{"releases": "must not contain more than one release per country and type"})
+	case errors.Is(err, data.ErrInvalidCursor):
+		return grpcValidationError(map[string]string{"cursor": "must be a valid cursor for the requested sort"})
+	default:
+		app.logger.PrintError(err, nil)
+		return status.Error(codes.Internal, serverErrorMessage)
+	}
+}
+
+func grpcValidationError(errors map[string]string) error {
+	violations := make([]*errdetails.BadRequest_FieldViolation, 0, len(errors))
+	for field, description := range errors {
+		violations = append(violations, &errdetails.BadRequest_FieldViolation{Field: field, Description: description})
+	}
+	slices.SortFunc(violations, func(a, b *errdetails.BadRequest_FieldViolation) int {
+		return strings.Compare(a.Field, b.Field)
+	})
+	st, err := status.New(codes.InvalidArgument, "failed validation").WithDetails(&errdetails.BadRequest{FieldViolations: violations})
+	if err != nil {
+		return status.Error(codes.InvalidArgument, "failed validation")
+	}
+	return st.Err()
+}
+
+type movieServer struct {
+	moviesv1.UnimplementedMovieServiceServer
+	app *application
+}
+
+func (s movieServer) Get(ctx context.Context, req *moviesv1.GetMovieRequest) (*moviesv1.Movie, error) {
+	movie, err := s.app.models.Movies.Get(req.Id)
+	if err != nil {
+		return nil, s.app.grpcError(err)
+	}
+	return toRPCMovie(movie), nil
+}
+
+func (s movieServer) List(req *moviesv1.ListMoviesRequest, stream moviesv1.MovieService_ListServer) error {
+	search := data.MovieSearch{Title: req.Title, Type: req.Type, Genres: req.Genres}
+	filters := data.Filters{
+		Page:         1,
+		PageSize:     int(req.PageSize),
+		Sort:         req.Sort,
+		SortSafelist: []string{"id", "-id", "title", "-title", "year", "-year", "runtime", "-runtime", "relevance"},
+		UseCursor:    true,
+		SkipTotal:    true,
+	}
+	if filters.PageSize == 0 {
+		filters.PageSize = 20
+	}
+	if filters.Sort == "" {
+		filters.Sort = "id"
+		if search.Title != "" {
+			filters.Sort = "relevance"
+		}
+	}
+	v := validator.New()
+	data.ValidateFilters(v, filters)
+	v.Check(req.Limit >= 0, "limit", "must not be negative")
+	v.Check(filters.Sort != "relevance" || search.Title != "", "sort", "relevance is only valid with a title query")
+	v.Check(search.Type == "" || validator.In(search.Type, data.MovieTypes...), "type", "must be one of: "+strings.Join(data.MovieTypes, ", "))
+	if !v.Valid() {
+		return grpcValidationError(v.Errors)
+	}
+
+	sent := int32(0)
+	for {
+		movies, metadata, err := s.app.models.Movies.GetAll(search, filters)
+		if err != nil {
+			return s.app.grpcError(err)
+		}
+		for _, movie := range movies {
+			err = stream.Send(toRPCMovie(movie))
+			if err != nil {
+				return err
+			}
+			sent++
+			if req.Limit > 0 && sent >= req.Limit {
+				return nil
+			}
+		}
+		if metadata.NextCursor == "" {
+			return nil
+		}
+		if err := stream.Context().Err(); err != nil {
+			return status.FromContextError(err).Err()
+		}
+		filters.Cursor = metadata.NextCursor
+	}
+}
+
+func (s movieServer) Create(ctx context.Context, req *moviesv1.CreateMovieRequest) (*moviesv1.Movie, error) {
+	if req.Movie == nil {
+		return nil, grpcValidationError(map[string]string{"movie": "must be provided"})
+	}
+	input := movieInput{
+		Type:        req.Movie.Type,
+		Title:       req.Movie.Title,
+		Year:        req.Movie.Year,
+		Runtime:     data.Runtime(req.Movie.Runtime),
+		Genres:      req.Movie.Genres,
+		ExternalIDs: req.Movie.ExternalIds,
+	}
+	movie := input.movie()
+	v := validator.New()
+	if data.ValidateMovie(v, movie); !v.Valid() {
+		return nil, grpcValidationError(v.Errors)
+	}
+	if !req.Force {
+		candidates, err := s.app.models.Movies.FindDuplicates(movie.Title, movie.Year)
+		if err != nil {
+			return nil, s.app.grpcError(err)
+		}
+		if len(candidates) > 0 {
+			return nil, status.Errorf(codes.AlreadyExists, "the movie looks like a duplicate of movie %d, retry with force to create it anyway", candidates[0].ID)
+		}
+	}
+	err := s.app.models.Movies.Insert(movie)
+	if err != nil {
+		return nil, s.app.grpcError(err)
+	}
+	return toRPCMovie(movie), nil
+}
+
+func (s movieServer) Update(ctx context.Context, req *moviesv1.UpdateMovieRequest) (*moviesv1.Movie, error) {
+	v := validator.New()
+	v.Check(req.Movie != nil, "movie", "must be provided")
+	v.Check(len(req.UpdateMask) > 0, "update_mask", "must be provided")
+	for _, path := range req.UpdateMask {
+		v.Check(validator.In(path, movieUpdateMaskPaths...), "update_mask", "must only contain: "+strings.Join(movieUpdateMaskPaths, ", "))
+	}
+	if !v.Valid() {
+		return nil, grpcValidationError(v.Errors)
+	}
+	movie, err := s.app.models.Movies.Get(req.Movie.Id)
+	if err != nil {
+		return nil, s.app.grpcError(err)
+	}
+	if req.Movie.Version != 0 && req.Movie.Version != movie.Version {
+		return nil, s.app.grpcError(data.ErrEditConflict)
+	}
+	var input moviePatch
+	for _, path := range req.UpdateMask {
+		switch path {
+		case "title":
+			input.Title = &req.Movie.Title
+		case "year":
+			input.Year = &req.Movie.Year
+		case "runtime":
+			runtime := data.Runtime(req.Movie.Runtime)
+			input.Runtime = &runtime
+		case "genres":
+			input.Genres = append([]string{}, req.Movie.Genres...)
+		case "external_ids":
+			movie.ExternalIDs = req.Movie.ExternalIds
+		}
+	}
+	input.apply(movie)
+	if data.ValidateMovie(v, movie); !v.Valid() {
+		return nil, grpcValidationError(v.Errors)
+	}
+	err = s.app.models.Movies.Update(movie)
+	if err != nil {
+		return nil, s.app.grpcError(err)
+	}
+	return toRPCMovie(movie), nil
+}
+
+func (s movieServer) Delete(ctx context.Context, req *moviesv1.DeleteMovieRequest) (*moviesv1.DeleteMovieResponse, error) {
+	err := s.app.deleteMovie(req.Id)
+	if err != nil {
+		return nil, s.app.grpcError(err)
+	}
+	return &moviesv1.DeleteMovieResponse{}, nil
+}
+
+func (app *application) grpcRecoverPanic(method string, err *error) {
+	if p := recover(); p != nil {
+		app.logger.PrintError(fmt.Errorf("%s", p), map[string]string{"grpc_method": method})
+		*err = status.Error(codes.Internal, serverErrorMessage)
+	}
+}
+
+func (app *application) grpcRecoverUnary(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (res any, err error) {
+	defer app.grpcRecoverPanic(info.FullMethod, &err)
+	return handler(ctx, req)
+}
+
+func (app *application) grpcRecoverStream(srv any, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+	defer app.grpcRecoverPanic(info.FullMethod, &err)
+	return handler(srv, stream)
+}
+
+func (app *application) grpcAllow(ctx context.Context, limiter *clientLimiter) error {
+	if !limiter.cfg.enabled {
+		return nil
+	}
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return status.Error(codes.Internal, serverErrorMessage)
+	}
+	ip, _, err := net.SplitHostPort(p.Addr.String())
+	if err != nil {
+		return app.grpcError(err)
+	}
+	if !limiter.allow(ip) {
+		return status.Error(codes.ResourceExhausted, "rate limit exceeded")
+	}
+	return nil
+}
+
+func (app *application) grpcRateLimit(limiter *clientLimiter) (grpc.UnaryServerInterceptor, grpc.StreamServerInterceptor) {
+	unary := func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if err := app.grpcAllow(ctx, limiter); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+	stream := func(srv any, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if err := app.grpcAllow(stream.Context(), limiter); err != nil {
+			return err
+		}
+		return handler(srv, stream)
+	}
+	return unary, stream
+}
+
+func (app *application) grpcServer() *grpc.Server {
+	rateLimitUnary, rateLimitStream := app.grpcRateLimit(newClientLimiter(app.config.limiter))
+	server := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(app.grpcRecoverUnary, rateLimitUnary),
+		grpc.ChainStreamInterceptor(app.grpcRecoverStream, rateLimitStream),
+	)
+	moviesv1.RegisterMovieServiceServer(server, movieServer{app: app})
+	return server
+}
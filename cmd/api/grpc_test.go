@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/noonacedia/cinematrique/internal/data"
+	"github.com/noonacedia/cinematrique/rpc/moviesv1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func newTestMovieClient(t *testing.T, app *application) moviesv1.MovieServiceClient {
+	t.Helper()
+	lis := bufconn.Listen(1 << 20)
+	server := app.grpcServer()
+	go server.Serve(lis)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return moviesv1.NewMovieServiceClient(conn)
+}
+
+func TestMovieServiceSpeaksProtobuf(t *testing.T) {
+	app := newTestApplication(t)
+	app.models.Movies = storedMovie{movie: &data.Movie{
+		ID:          1,
+		Type:        "movie",
+		Title:       "Moana",
+		Year:        2016,
+		Runtime:     107,
+		Genres:      []string{"animation"},
+		Version:     2,
+		ExternalIDs: map[string]string{"imdb": "tt3521164"},
+	}}
+	client := newTestMovieClient(t, app)
+	ctx := context.Background()
+
+	movie, err := client.Get(ctx, &moviesv1.GetMovieRequest{Id: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if movie.GetTitle() != "Moana" || movie.GetRuntime() != 107 || movie.GetExternalIds()["imdb"] != "tt3521164" {
+		t.Errorf("Get = %v", movie)
+	}
+
+	_, err = client.Get(ctx, &moviesv1.GetMovieRequest{Id: 2})
+	if status.Code(err) != codes.NotFound {
+		t.Errorf("Get missing movie error = %v, want NotFound", err)
+	}
+
+	stream, err := client.List(ctx, &moviesv1.ListMoviesRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []int64
+	for {
+		movie, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, movie.GetId())
+	}
+	if len(ids) != 1 || ids[0] != 1 {
+		t.Errorf("List ids = %v, want [1]", ids)
+	}
+
+	_, err = client.Update(ctx, &moviesv1.UpdateMovieRequest{Movie: &moviesv1.Movie{Id: 1}, UpdateMask: []string{"score"}})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("Update with unknown mask path error = %v, want InvalidArgument", err)
+	}
+}
@@ -1,15 +1,19 @@
 package main
 
 import (
+	"cmp"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
+	"slices"
 	"strconv"
 	"strings"
+	"time"
 
+	"github.com/noonacedia/cinematrique/internal/data"
 	"github.com/noonacedia/cinematrique/internal/validator"
 )
 
@@ -24,16 +28,25 @@ func (app *application) readIDPathParam(r *http.Request) (int, error) {
 }
 
 func (app *application) writeJSON(w http.ResponseWriter, status int, data envelope, headers http.Header) error {
-	jsonText, err := json.Marshal(data)
+	jsonText, err := json.Marshal(applyRuntimeFormat(w, data))
+	if err != nil {
+		return err
+	}
+	body, contentType, err := encodeResponse(w, status, jsonText)
+	if errors.Is(err, errNotAcceptable) {
+		status, headers = http.StatusNotAcceptable, nil
+		body, err = json.Marshal(envelope{"error": notAcceptableMessage})
+		body, contentType = append(body, '\n'), "application/json"
+	}
 	if err != nil {
 		return err
 	}
 	for key, value := range headers {
 		w.Header()[key] = value
 	}
-	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Type", contentType)
 	w.WriteHeader(status)
-	w.Write(append(jsonText, '\n'))
+	w.Write(body)
 	return nil
 }
 
@@ -93,6 +106,17 @@ func (app *application) readCSV(qs url.Values, key string, defaultValue []string
 	return strings.Split(csv, ",")
 }
 
+func (app *application) readFieldList(qs url.Values, key string, safelist []string, v *validator.Validator) []string {
+	fields := app.readCSV(qs, key, nil)
+	for i, field := range fields {
+		fields[i] = strings.TrimSpace(field)
+		if !validator.In(fields[i], safelist...) {
+			v.AddError(key, fmt.Sprintf("unknown value %q, must be one of: %s", fields[i], strings.Join(safelist, ", ")))
+		}
+	}
+	return fields
+}
+
 func (app *application) readInt(qs url.Values, key string, defaultValue int, v *validator.Validator) int {
 	num := qs.Get(key)
 	if num == "" {
@@ -106,6 +130,81 @@ func (app *application) readInt(qs url.Values, key string, defaultValue int, v *
 	return converted
 }
 
+func (app *application) readDate(qs url.Values, key string, v *validator.Validator) time.Time {
+	s := qs.Get(key)
+	if s == "" {
+		return time.Time{}
+	}
+	date, err := time.Parse(time.DateOnly, s)
+	if err != nil {
+		v.AddError(key, "must be a date in YYYY-MM-DD format")
+		return time.Time{}
+	}
+	return date
+}
+
+func (app *application) readBool(qs url.Values, key string, defaultValue bool, v *validator.Validator) bool {
+	s := qs.Get(key)
+	if s == "" {
+		return defaultValue
+	}
+	b, err := strconv.ParseBool(s)
+	if err != nil {
+		v.AddError(key, "must be a boolean value")
+		return defaultValue
+	}
+	return b
+}
+
+func (app *application) readAcceptLanguage(r *http.Request) []string {
+	type tag struct {
+		locale  string
+		quality float64
+	}
+	var tags []tag
+	for _, part := range strings.Split(r.Header.Get("Accept-Language"), ",") {
+		locale, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		locale = strings.ToLower(strings.TrimSpace(locale))
+		if locale == "" || locale == "*" {
+			continue
+		}
+		quality := 1.0
+		if q, found := strings.CutPrefix(strings.TrimSpace(params), "q="); found {
+			parsed, err := strconv.ParseFloat(q, 64)
+			if err != nil {
+				continue
+			}
+			quality = parsed
+		}
+		if quality <= 0 {
+			continue
+		}
+		tags = append(tags, tag{locale: locale, quality: quality})
+	}
+	slices.SortStableFunc(tags, func(a, b tag) int {
+		return cmp.Compare(b.quality, a.quality)
+	})
+	// A bare language is the fallback for its regional tags, tried after the
+	// last of them so "pt-BR, pt-PT" still prefers pt-PT over pt.
+	locales := make([]string, 0, len(tags))
+	for i, t := range tags {
+		if !slices.Contains(locales, t.locale) {
+			locales = append(locales, t.locale)
+		}
+		language, _, found := strings.Cut(t.locale, "-")
+		if !found || slices.Contains(locales, language) {
+			continue
+		}
+		later := slices.ContainsFunc(tags[i+1:], func(other tag) bool {
+			return other.locale == language || strings.HasPrefix(other.locale, language+"-")
+		})
+		if !later {
+			locales = append(locales, language)
+		}
+	}
+	return locales
+}
+
 func (app *application) runRecoverableBackground(backgroundTask func()) {
 	app.wg.Add(1)
 	go func() {
@@ -118,3 +217,40 @@ func (app *application) runRecoverableBackground(backgroundTask func()) {
 		backgroundTask()
 	}()
 }
+
+func (app *application) runPeriodic(interval time.Duration, task func() error) {
+	app.runRecoverableBackground(func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-app.done:
+				return
+			case <-ticker.C:
+				err := task()
+				if err != nil {
+					app.logger.PrintError(err, nil)
+				}
+			}
+		}
+	})
+}
+
+// requestedRuntimeFormat returns the format the runtimeFormat middleware
+// chose for w, or "" when runtimes keep their default encoding.
+func requestedRuntimeFormat(w http.ResponseWriter) data.RuntimeFormat {
+	for {
+		switch rw := w.(type) {
+		case *runtimeFormatWriter:
+			return rw.format
+		case interface{ Unwrap() http.ResponseWriter }:
+			w = rw.Unwrap()
+		default:
+			return ""
+		}
+	}
+}
+
+func applyRuntimeFormat(w http.ResponseWriter, value envelope) any {
+	return data.FormatRuntimes(value, requestedRuntimeFormat(w))
+}
@@ -0,0 +1,280 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/noonacedia/cinematrique/internal/data"
+	"github.com/noonacedia/cinematrique/internal/storage"
+	"github.com/noonacedia/cinematrique/internal/validator"
+	"golang.org/x/image/draw"
+)
+
+var imageExtensions = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+}
+
+func (app *application) uploadMovieImageHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+	_, err = app.models.Movies.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	// The server's ReadTimeout is sized for JSON bodies and would cut off an
+	// upload long before maxSize arrives on a slow link.
+	rc := http.NewResponseController(w)
+	deadline := time.Now().Add(app.config.images.uploadTimeout)
+	for _, extend := range []func(time.Time) error{rc.SetReadDeadline, rc.SetWriteDeadline} {
+		err = extend(deadline)
+		if err != nil && !errors.Is(err, http.ErrNotSupported) {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, app.config.images.maxSize+1_024*1_024)
+	err = r.ParseMultipartForm(1_024 * 1_024)
+	if err != nil {
+		app.badRequestResponse(w, r, fmt.Errorf("body must be a multipart form no larger than %d bytes", app.config.images.maxSize))
+		return
+	}
+	defer r.MultipartForm.RemoveAll()
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		app.badRequestResponse(w, r, errors.New("body must contain a file field"))
+		return
+	}
+	defer file.Close()
+
+	sniff := make([]byte, 512)
+	n, err := io.ReadFull(file, sniff)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	img := &data.MovieImage{
+		MovieID:     id,
+		Kind:        r.FormValue("kind"),
+		ContentType: http.DetectContentType(sniff[:n]),
+		Size:        header.Size,
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	v := validator.New()
+	if cfg, _, err := image.DecodeConfig(file); err == nil {
+		img.Width, img.Height = cfg.Width, cfg.Height
+	} else {
+		v.AddError("file", "must be a readable JPEG, PNG or GIF image")
+	}
+	if data.ValidateMovieImage(v, img, app.config.images.maxSize, app.config.images.maxPixels); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	img.Key, err = newImageKey(id, imageExtensions[img.ContentType])
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	err = app.storage.Put(img.Key, file)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	err = app.models.MovieImages.Insert(img)
+	if err != nil {
+		app.storage.Delete(img.Key)
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	pending := *img
+	app.runRecoverableBackground(func() {
+		err := app.generateThumbnail(&pending)
+		if err != nil {
+			app.logger.PrintError(err, map[string]string{"image_key": pending.Key})
+		}
+	})
+
+	app.setImageURLs(img)
+	headers := make(http.Header)
+	headers.Set("Location", img.URL)
+	err = app.writeJSON(w, http.StatusCreated, envelope{"image": img}, headers)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) serveImageHandler(w http.ResponseWriter, r *http.Request) {
+	key := r.PathValue("key")
+	// Dot files are the store's in-flight uploads, never finished images.
+	if strings.HasPrefix(path.Base(key), ".") {
+		app.notFoundResponse(w, r)
+		return
+	}
+	blob, err := app.storage.Get(key)
+	if err != nil {
+		switch {
+		case errors.Is(err, storage.ErrBlobNotFound), errors.Is(err, storage.ErrInvalidKey):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	defer blob.Close()
+	w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	w.Header().Set("ETag", strconv.Quote(key))
+	http.ServeContent(w, r, path.Base(key), blob.ModTime(), blob)
+}
+
+func (app *application) generateThumbnail(img *data.MovieImage) error {
+	if int64(img.Width)*int64(img.Height) > app.config.images.maxPixels {
+		return fmt.Errorf("image %dx%d exceeds the %d pixel limit", img.Width, img.Height, app.config.images.maxPixels)
+	}
+	blob, err := app.storage.Get(img.Key)
+	if err != nil {
+		return err
+	}
+	defer blob.Close()
+	src, _, err := image.Decode(blob)
+	if err != nil {
+		return err
+	}
+	bounds := src.Bounds()
+	width := min(app.config.images.thumbnailWidth, bounds.Dx())
+	height := max(1, bounds.Dy()*width/bounds.Dx())
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), src, bounds, draw.Over, nil)
+
+	var buf bytes.Buffer
+	switch img.ContentType {
+	case "image/png":
+		err = png.Encode(&buf, dst)
+	case "image/gif":
+		err = gif.Encode(&buf, dst, nil)
+	default:
+		err = jpeg.Encode(&buf, dst, &jpeg.Options{Quality: 85})
+	}
+	if err != nil {
+		return err
+	}
+	thumbnail := &data.Thumbnail{
+		Key:    thumbnailKey(img.Key),
+		Width:  width,
+		Height: height,
+	}
+	err = app.storage.Put(thumbnail.Key, &buf)
+	if err != nil {
+		return err
+	}
+	err = app.models.MovieImages.SetThumbnail(img.ID, thumbnail)
+	if err != nil {
+		// The image row went away with its movie while the thumbnail was
+		// being made, so nothing will ever reference the file.
+		app.storage.Delete(thumbnail.Key)
+		return err
+	}
+	return nil
+}
+
+// removeImageBlobs deletes the stored files behind images whose rows are
+// already gone, logging rather than failing since the delete has happened.
+func (app *application) removeImageBlobs(images []*data.MovieImage) {
+	for _, img := range images {
+		keys := []string{img.Key}
+		if img.Thumbnail != nil {
+			keys = append(keys, img.Thumbnail.Key)
+		}
+		for _, key := range keys {
+			err := app.storage.Delete(key)
+			if err != nil {
+				app.logger.PrintError(err, map[string]string{"image_key": key})
+			}
+		}
+	}
+}
+
+// deleteMovie deletes a movie and then the image files its rows pointed at.
+func (app *application) deleteMovie(id int64) error {
+	images, err := app.models.MovieImages.GetAllForMovies([]int64{id})
+	if err != nil {
+		return err
+	}
+	err = app.models.Movies.Delete(id)
+	if err != nil {
+		return err
+	}
+	app.removeImageBlobs(images[id])
+	return nil
+}
+
+func (app *application) setImageURLs(img *data.MovieImage) {
+	img.URL = "/v1/images/" + img.Key
+	if img.Thumbnail != nil {
+		img.Thumbnail.URL = "/v1/images/" + img.Thumbnail.Key
+	}
+}
+
+func (app *application) attachImages(movies ...*data.Movie) error {
+	if len(movies) == 0 {
+		return nil
+	}
+	ids := make([]int64, len(movies))
+	for i, movie := range movies {
+		ids[i] = movie.ID
+	}
+	images, err := app.models.MovieImages.GetAllForMovies(ids)
+	if err != nil {
+		return err
+	}
+	for _, movie := range movies {
+		for _, img := range images[movie.ID] {
+			app.setImageURLs(img)
+		}
+		movie.Images = images[movie.ID]
+	}
+	return nil
+}
+
+func newImageKey(movieID int64, extension string) (string, error) {
+	random := make([]byte, 16)
+	_, err := rand.Read(random)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("movies/%d/%s%s", movieID, hex.EncodeToString(random), extension), nil
+}
+
+func thumbnailKey(key string) string {
+	extension := path.Ext(key)
+	return key[:len(key)-len(extension)] + "_thumb" + extension
+}
@@ -0,0 +1,255 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"image"
+	"image/color"
+	"image/gif"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/noonacedia/cinematrique/internal/data"
+	"github.com/noonacedia/cinematrique/internal/storage"
+)
+
+func newTestImageStore(t *testing.T, app *application) string {
+	t.Helper()
+	dir := t.TempDir()
+	store, err := storage.NewLocalStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	app.storage = store
+	// Registered after TempDir so thumbnails finish before it is removed.
+	t.Cleanup(app.wg.Wait)
+	return dir
+}
+
+func tinyGIF(t *testing.T) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	err := gif.Encode(&buf, image.NewPaletted(image.Rect(0, 0, 1, 1), []color.Color{color.Black}), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func multipartImage(t *testing.T, file []byte) (string, http.Header) {
+	t.Helper()
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	mw.WriteField("kind", "poster")
+	fw, err := mw.CreateFormFile("file", "poster")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fw.Write(file)
+	mw.Close()
+	return body.String(), http.Header{"Content-Type": {mw.FormDataContentType()}}
+}
+
+func TestUploadMovieImageRejectsUnsafeFiles(t *testing.T) {
+	// A GIF's logical screen size lives in bytes 6-9 and is not covered by
+	// any checksum, so a tiny file can claim an enormous canvas.
+	huge := tinyGIF(t)
+	binary.LittleEndian.PutUint16(huge[6:], 50_000)
+	binary.LittleEndian.PutUint16(huge[8:], 50_000)
+
+	tests := []struct {
+		name string
+		file []byte
+	}{
+		{"too many pixels", huge},
+		{"undecodable", append([]byte("\x89PNG\r\n\x1a\n"), bytes.Repeat([]byte{0xff}, 64)...)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(t)
+			app.config.images.maxSize = 1_024 * 1_024
+			app.config.images.maxPixels = 40_000_000
+			body, header := multipartImage(t, tt.file)
+			rr := serve(t, app.routes(), http.MethodPost, "/v1/movies/1/images", body, header)
+			if rr.Code != http.StatusUnprocessableEntity {
+				t.Fatalf("status = %d, want %d: %s", rr.Code, http.StatusUnprocessableEntity, rr.Body)
+			}
+			var res struct {
+				Error map[string]string `json:"error"`
+			}
+			if err := json.Unmarshal(rr.Body.Bytes(), &res); err != nil {
+				t.Fatal(err)
+			}
+			if res.Error["file"] == "" {
+				t.Errorf("expected a file error, got %v", res.Error)
+			}
+		})
+	}
+}
+
+func TestGenerateThumbnailRefusesOversizedImages(t *testing.T) {
+	app := newTestApplication(t)
+	app.config.images.maxPixels = 40_000_000
+	err := app.generateThumbnail(&data.MovieImage{Key: "movies/1/x.gif", Width: 50_000, Height: 50_000})
+	if err == nil {
+		t.Fatal("expected an error for an image over the pixel limit")
+	}
+}
+
+func TestUploadMovieImageOutlastsReadTimeout(t *testing.T) {
+	app := newTestApplication(t)
+	app.config.images.maxSize = 1_024 * 1_024
+	app.config.images.maxPixels = 40_000_000
+	app.config.images.thumbnailWidth = 320
+	app.config.images.uploadTimeout = 10 * time.Second
+	newTestImageStore(t, app)
+	srv := httptest.NewUnstartedServer(app.routes())
+	srv.Config.ReadTimeout = 200 * time.Millisecond
+	srv.Start()
+	defer srv.Close()
+
+	body, header := multipartImage(t, tinyGIF(t))
+	pr, pw := io.Pipe()
+	go func() {
+		half := len(body) / 2
+		io.WriteString(pw, body[:half])
+		time.Sleep(3 * srv.Config.ReadTimeout)
+		io.WriteString(pw, body[half:])
+		pw.Close()
+	}()
+	req, err := http.NewRequest(http.MethodPost, srv.URL+"/v1/movies/1/images", pr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", header.Get("Content-Type"))
+	res, err := srv.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusCreated {
+		msg, _ := io.ReadAll(res.Body)
+		t.Fatalf("status = %d, want %d: %s", res.StatusCode, http.StatusCreated, msg)
+	}
+}
+
+func TestServeImageHidesPartialUploads(t *testing.T) {
+	app := newTestApplication(t)
+	dir := newTestImageStore(t, app)
+	err := os.MkdirAll(filepath.Join(dir, "movies", "1"), 0o755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"poster.gif", ".upload-123"} {
+		err := os.WriteFile(filepath.Join(dir, "movies", "1", name), tinyGIF(t), 0o644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	tests := []struct {
+		key  string
+		want int
+	}{
+		{"movies/1/poster.gif", http.StatusOK},
+		{"movies/1/.upload-123", http.StatusNotFound},
+		{"movies/1/missing.gif", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		rr := serve(t, app.routes(), http.MethodGet, "/v1/images/"+tt.key, "", nil)
+		if rr.Code != tt.want {
+			t.Errorf("GET %s status = %d, want %d", tt.key, rr.Code, tt.want)
+		}
+	}
+}
+
+// storedImages serves fixed image rows and reports every thumbnail update
+// as a missing image, as if the movie had been deleted meanwhile.
+type storedImages struct {
+	data.MockMovieImageModel
+	images map[int64][]*data.MovieImage
+}
+
+func (m storedImages) GetAllForMovies(movieIDs []int64) (map[int64][]*data.MovieImage, error) {
+	return m.images, nil
+}
+
+func (m storedImages) SetThumbnail(id int64, thumbnail *data.Thumbnail) error {
+	return data.ErrRecordNotFound
+}
+
+func TestDeletingMovieRemovesImageFiles(t *testing.T) {
+	tests := []struct {
+		name   string
+		method string
+		target string
+		body   string
+	}{
+		{"delete", http.MethodDelete, "/v1/movies/1", ""},
+		{"batch", http.MethodPost, "/v1/batch", `{"operations":[{"op":"delete","id":1}]}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(t)
+			dir := newTestImageStore(t, app)
+			keys := []string{"movies/1/a.gif", "movies/1/a_thumb.gif", "movies/2/b.gif"}
+			for _, key := range keys {
+				err := app.storage.Put(key, bytes.NewReader(tinyGIF(t)))
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+			app.models.MovieImages = storedImages{images: map[int64][]*data.MovieImage{
+				1: {{ID: 1, MovieID: 1, Key: keys[0], Thumbnail: &data.Thumbnail{Key: keys[1]}}},
+				2: {{ID: 2, MovieID: 2, Key: keys[2]}},
+			}}
+
+			rr := serve(t, app.routes(), tt.method, tt.target, tt.body, nil)
+			if rr.Code >= 300 {
+				t.Fatalf("status = %d: %s", rr.Code, rr.Body)
+			}
+			for _, key := range keys[:2] {
+				if _, err := os.Stat(filepath.Join(dir, key)); !errors.Is(err, os.ErrNotExist) {
+					t.Errorf("%s still exists after the movie was deleted", key)
+				}
+			}
+			if _, err := os.Stat(filepath.Join(dir, keys[2])); err != nil {
+				t.Errorf("another movie's image was removed: %v", err)
+			}
+		})
+	}
+}
+
+func TestGenerateThumbnailCleansUpForDeletedImages(t *testing.T) {
+	app := newTestApplication(t)
+	app.config.images.maxPixels = 40_000_000
+	app.config.images.thumbnailWidth = 320
+	dir := newTestImageStore(t, app)
+	app.models.MovieImages = storedImages{}
+	img := &data.MovieImage{ID: 1, Key: "movies/1/a.gif", ContentType: "image/gif", Width: 1, Height: 1}
+	err := app.storage.Put(img.Key, bytes.NewReader(tinyGIF(t)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = app.generateThumbnail(img)
+	if !errors.Is(err, data.ErrRecordNotFound) {
+		t.Fatalf("err = %v, want ErrRecordNotFound", err)
+	}
+	entries, err := os.ReadDir(filepath.Join(dir, "movies", "1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, entry := range entries {
+		if strings.Contains(entry.Name(), "_thumb") {
+			t.Errorf("orphaned thumbnail %s was left behind", entry.Name())
+		}
+	}
+}
@@ -2,33 +2,99 @@ package main
 
 import (
 	"context"
+	"crypto/rand"
 	"database/sql"
 	"flag"
+	"net/http"
 	"os"
+	"strings"
 	"sync"
 	"time"
 
+	"github.com/graphql-go/graphql"
 	_ "github.com/lib/pq"
 	"github.com/noonacedia/cinematrique/internal/data"
 	"github.com/noonacedia/cinematrique/internal/jsonlog"
 	"github.com/noonacedia/cinematrique/internal/mailer"
+	"github.com/noonacedia/cinematrique/internal/storage"
 )
 
 const version = "1.0.0"
 
+type limiterConfig struct {
+	rps     float64
+	burst   int
+	enabled bool
+}
+
 type config struct {
 	port int
 	env  string
-	db   struct {
+	grpc struct {
+		port int
+	}
+	db struct {
 		dsn          string
 		maxOpenConns int
 		maxIdleConns int
 		maxIdleTime  time.Duration
 	}
-	limiter struct {
-		rps     float64
-		burst   int
+	limiter limiterConfig
+	suggest struct {
+		limit   int
+		limiter limiterConfig
+	}
+	images struct {
+		dir            string
+		maxSize        int64
+		maxPixels      int64
+		thumbnailWidth int
+		uploadTimeout  time.Duration
+	}
+	similar struct {
+		refreshInterval time.Duration
+	}
+	cursor struct {
+		secret string
+	}
+	webhooks struct {
+		pollInterval time.Duration
+		batchSize    int
+		maxAttempts  int
+		timeout      time.Duration
+	}
+	outbox struct {
+		pollInterval time.Duration
+		batchSize    int
+		sinks        []string
+		retention    time.Duration
+	}
+	events struct {
+		heartbeat    time.Duration
+		pollInterval time.Duration
+		buffer       int
+		writeTimeout time.Duration
+	}
+	idempotency struct {
+		ttl         time.Duration
+		lockTimeout time.Duration
+	}
+	graphql struct {
+		maxDepth      int
+		maxComplexity int
+	}
+	metrics struct {
+		addr string
+	}
+	cache struct {
+		enabled bool
+		size    int
+		ttl     time.Duration
+		listTTL time.Duration
+	}
+	compress struct {
 		enabled bool
+		minSize int
 	}
 	smtp struct {
 		host     string
@@ -40,16 +106,25 @@ type config struct {
 }
 
 type application struct {
-	config config
-	logger *jsonlog.Logger
-	models data.Models
-	mailer mailer.Mailer
-	wg     sync.WaitGroup
+	config        config
+	logger        *jsonlog.Logger
+	models        data.Models
+	mailer        mailer.Mailer
+	storage       storage.BlobStore
+	graphql       graphql.Schema
+	cache         *data.CachedMovieModel
+	webhookClient *http.Client
+	events        *eventHub
+	changes       *changeFanout
+	outboxWake    chan struct{}
+	wg            sync.WaitGroup
+	done          chan struct{}
 }
 
 func main() {
 	var cfg config
 	flag.IntVar(&cfg.port, "port", 8000, "API server port")
+	flag.IntVar(&cfg.grpc.port, "grpc-port", 9000, "gRPC server port (0 disables the gRPC server)")
 	flag.StringVar(&cfg.env, "env", "development", "Environment (development|staging|production)")
 
 	flag.StringVar(&cfg.db.dsn, "db-dsn", os.Getenv("CINEMATRIQUE_DB_DSN"), "PostgreSQL DSN")
@@ -61,6 +136,54 @@ func main() {
 	flag.IntVar(&cfg.limiter.burst, "limiter-burst", 4, "Rate limiter maximum burst")
 	flag.BoolVar(&cfg.limiter.enabled, "limiter-enabled", true, "Enable rate limiter")
 
+	flag.IntVar(&cfg.suggest.limit, "suggest-limit", 10, "Default number of title suggestions")
+	flag.Float64Var(&cfg.suggest.limiter.rps, "suggest-limiter-rps", 10, "Suggestions rate limiter maximum requests per second")
+	flag.IntVar(&cfg.suggest.limiter.burst, "suggest-limiter-burst", 20, "Suggestions rate limiter maximum burst")
+	flag.BoolVar(&cfg.suggest.limiter.enabled, "suggest-limiter-enabled", true, "Enable suggestions rate limiter")
+
+	flag.StringVar(&cfg.images.dir, "images-dir", "./uploads", "Directory for uploaded images")
+	flag.Int64Var(&cfg.images.maxSize, "images-max-size", 10*1_024*1_024, "Maximum uploaded image size in bytes")
+	flag.Int64Var(&cfg.images.maxPixels, "images-max-pixels", 40_000_000, "Maximum uploaded image width times height in pixels")
+	flag.IntVar(&cfg.images.thumbnailWidth, "images-thumbnail-width", 320, "Width of generated image thumbnails")
+	flag.DurationVar(&cfg.images.uploadTimeout, "images-upload-timeout", time.Minute, "Time allowed to receive and answer an image upload")
+
+	flag.DurationVar(&cfg.similar.refreshInterval, "similar-refresh-interval", time.Hour, "Interval between similar movies refreshes")
+
+	flag.StringVar(&cfg.cursor.secret, "cursor-secret", os.Getenv("CINEMATRIQUE_CURSOR_SECRET"), "Secret used to sign pagination cursors")
+
+	flag.DurationVar(&cfg.webhooks.pollInterval, "webhooks-poll-interval", 5*time.Second, "Interval between webhook delivery runs")
+	flag.IntVar(&cfg.webhooks.batchSize, "webhooks-batch-size", 50, "Maximum webhook deliveries attempted per run")
+	flag.IntVar(&cfg.webhooks.maxAttempts, "webhooks-max-attempts", 10, "Webhook delivery attempts before giving up")
+	flag.DurationVar(&cfg.webhooks.timeout, "webhooks-timeout", 10*time.Second, "Webhook delivery request timeout")
+
+	flag.DurationVar(&cfg.outbox.pollInterval, "outbox-poll-interval", time.Second, "Interval between outbox relay runs")
+	flag.IntVar(&cfg.outbox.batchSize, "outbox-batch-size", 100, "Maximum outbox events relayed per query")
+	flag.DurationVar(&cfg.outbox.retention, "outbox-retention", 7*24*time.Hour, "How long published outbox events are kept for event stream resumption")
+	flag.Func("outbox-sinks", "Comma-separated outbox event sinks (webhooks|log|sse)", func(val string) error {
+		cfg.outbox.sinks = strings.Split(val, ",")
+		return nil
+	})
+
+	flag.DurationVar(&cfg.events.heartbeat, "events-heartbeat", 15*time.Second, "Interval between event stream heartbeats")
+	flag.DurationVar(&cfg.events.pollInterval, "events-poll-interval", 5*time.Second, "Interval between outbox polls feeding event streams")
+	flag.IntVar(&cfg.events.buffer, "events-buffer", 64, "Events buffered per stream subscriber before it is disconnected")
+	flag.DurationVar(&cfg.events.writeTimeout, "events-write-timeout", 10*time.Second, "Event stream per-write timeout")
+
+	flag.DurationVar(&cfg.idempotency.ttl, "idempotency-ttl", 24*time.Hour, "How long idempotent responses are kept for replay")
+	flag.DurationVar(&cfg.idempotency.lockTimeout, "idempotency-lock-timeout", time.Minute, "How long an unfinished idempotent request blocks retries")
+
+	flag.IntVar(&cfg.graphql.maxDepth, "graphql-max-depth", 8, "Maximum GraphQL query depth")
+	flag.IntVar(&cfg.graphql.maxComplexity, "graphql-max-complexity", 5_000, "Maximum GraphQL query complexity")
+
+	flag.StringVar(&cfg.metrics.addr, "metrics-addr", "127.0.0.1:4001", "Internal metrics listen address (empty disables the metrics server)")
+	flag.BoolVar(&cfg.cache.enabled, "cache-enabled", true, "Enable the in-memory movie cache")
+	flag.IntVar(&cfg.cache.size, "cache-size", 1_000, "Maximum movies and movie lists held in the cache")
+	flag.DurationVar(&cfg.cache.ttl, "cache-ttl", 5*time.Minute, "How long a cached movie is served")
+	flag.DurationVar(&cfg.cache.listTTL, "cache-list-ttl", 30*time.Second, "How long a cached movie list is served")
+
+	flag.BoolVar(&cfg.compress.enabled, "compress-enabled", true, "Compress responses with gzip, deflate or zstd")
+	flag.IntVar(&cfg.compress.minSize, "compress-min-size", 1_024, "Smallest response body in bytes worth compressing")
+
 	flag.StringVar(&cfg.smtp.host, "smtp-host", "sandbox.smtp.mailtrap.io", "SMTP host")
 	flag.IntVar(&cfg.smtp.port, "smtp-port", 25, "SMTP port")
 	flag.StringVar(&cfg.smtp.username, "smtp-username", "0cd7f5d1aac8df", "SMTP username")
@@ -68,18 +191,34 @@ func main() {
 	flag.StringVar(&cfg.smtp.sender, "smtp-sender", "Cinematrique <no-reply@cinematrique.com>", "SMTP sender")
 
 	flag.Parse()
+	if cfg.outbox.sinks == nil {
+		cfg.outbox.sinks = []string{"webhooks", "sse"}
+	}
 
 	logger := jsonlog.New(os.Stdout, jsonlog.LevelInfo)
+	if cfg.cursor.secret == "" {
+		secret := make([]byte, 32)
+		_, err := rand.Read(secret)
+		if err != nil {
+			logger.PrintFatal(err, nil)
+		}
+		cfg.cursor.secret = string(secret)
+		logger.PrintInfo("cursor secret is not set, pagination cursors will not survive restarts", nil)
+	}
 	db, err := openDB(cfg)
 	if err != nil {
 		logger.PrintFatal(err, nil)
 	}
 	defer db.Close()
 	logger.PrintInfo("db connection pool established", nil)
+	store, err := storage.NewLocalStore(cfg.images.dir)
+	if err != nil {
+		logger.PrintFatal(err, nil)
+	}
 	app := &application{
 		config: cfg,
 		logger: logger,
-		models: data.NewModels(db),
+		models: data.NewModels(db, []byte(cfg.cursor.secret)),
 		mailer: mailer.New(
 			cfg.smtp.host,
 			cfg.smtp.port,
@@ -87,7 +226,41 @@ func main() {
 			cfg.smtp.password,
 			cfg.smtp.sender,
 		),
+		storage:       store,
+		webhookClient: newWebhookClient(cfg.webhooks.timeout),
+		events:        newEventHub(),
+		changes:       &changeFanout{},
+		outboxWake:    make(chan struct{}, 1),
+		done:          make(chan struct{}),
+	}
+	app.graphql, err = app.newGraphQLSchema()
+	if err != nil {
+		logger.PrintFatal(err, nil)
+	}
+	if cfg.cache.enabled {
+		app.cache = data.NewCachedMovieModel(app.models.Movies, cfg.cache.size, cfg.cache.ttl, cfg.cache.listTTL)
+		app.models.Movies = app.cache
+		app.changes.Subscribe(app.cache.Invalidate)
+	}
+	err = app.listenMovieChanges()
+	if err != nil {
+		logger.PrintFatal(err, nil)
+	}
+	err = app.followOutbox()
+	if err != nil {
+		logger.PrintFatal(err, nil)
+	}
+	sinks, err := app.outboxSinks()
+	if err != nil {
+		logger.PrintFatal(err, nil)
 	}
+	app.runOutboxRelay(sinks)
+	app.runPeriodic(cfg.similar.refreshInterval, app.models.Movies.RefreshSimilarities)
+	app.runPeriodic(time.Hour, app.models.IdempotencyKeys.DeleteExpired)
+	app.runPeriodic(time.Hour, func() error {
+		return app.models.Outbox.DeletePublished(cfg.outbox.retention)
+	})
+	app.runPeriodic(cfg.webhooks.pollInterval, app.deliverWebhooks)
 	err = app.serve()
 	if err != nil {
 		logger.PrintFatal(err, nil)
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/noonacedia/cinematrique/internal/data"
+)
+
+// metricsRoutes is served on the internal metrics listener, never on the
+// public port.
+func (app *application) metricsRoutes() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /debug/cache", app.cacheStatsHandler)
+	return mux
+}
+
+func (app *application) cacheStatsHandler(w http.ResponseWriter, r *http.Request) {
+	var stats *data.CacheStats
+	if app.cache != nil {
+		s := app.cache.Stats()
+		stats = &s
+	}
+	err := app.writeJSON(w, http.StatusOK, envelope{"movie_cache": stats}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
@@ -0,0 +1,24 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/noonacedia/cinematrique/internal/data"
+)
+
+func TestMetricsAreInternal(t *testing.T) {
+	app := newTestApplication(t)
+	app.cache = data.NewCachedMovieModel(app.models.Movies, 10, time.Minute, time.Minute)
+	for _, target := range []string{"/debug/vars", "/debug/cache"} {
+		if rr := serve(t, app.routes(), http.MethodGet, target, "", nil); rr.Code != http.StatusNotFound {
+			t.Errorf("public GET %s = %d, want %d", target, rr.Code, http.StatusNotFound)
+		}
+	}
+	rr := serve(t, app.metricsRoutes(), http.MethodGet, "/debug/cache", "", nil)
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), `"movie_cache":{"hits":0`) {
+		t.Errorf("metrics GET /debug/cache = %d %s", rr.Code, rr.Body)
+	}
+}
@@ -1,12 +1,20 @@
 package main
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
 	"fmt"
+	"io"
 	"net"
 	"net/http"
+	"slices"
+	"strings"
 	"sync"
 	"time"
 
+	"github.com/noonacedia/cinematrique/internal/data"
+	"github.com/noonacedia/cinematrique/internal/validator"
 	"golang.org/x/time/rate"
 )
 
@@ -23,51 +31,251 @@ func (app *application) recoverPanic(next http.Handler) http.Handler {
 }
 
 func (app *application) rateLimit(next http.Handler) http.Handler {
+	return app.rateLimitWith(app.config.limiter, next)
+}
 
-	type client struct {
-		limiter  *rate.Limiter
-		lastSeen time.Time
-	}
-	var (
-		mu      sync.Mutex
-		clients = make(map[string]*client)
-	)
+type clientLimiter struct {
+	cfg     limiterConfig
+	mu      sync.Mutex
+	clients map[string]*limitedClient
+}
+
+type limitedClient struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+func newClientLimiter(cfg limiterConfig) *clientLimiter {
+	l := &clientLimiter{cfg: cfg, clients: make(map[string]*limitedClient)}
 	go func() {
 		for {
 			time.Sleep(time.Minute)
-			mu.Lock()
-			for ip, client := range clients {
+			l.mu.Lock()
+			for ip, client := range l.clients {
 				if time.Since(client.lastSeen) > 3*time.Minute {
-					delete(clients, ip)
+					delete(l.clients, ip)
 				}
 			}
-			mu.Unlock()
+			l.mu.Unlock()
 		}
 	}()
+	return l
+}
 
+func (l *clientLimiter) allow(ip string) bool {
+	if !l.cfg.enabled {
+		return true
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, found := l.clients[ip]; !found {
+		l.clients[ip] = &limitedClient{
+			limiter: rate.NewLimiter(rate.Limit(l.cfg.rps), l.cfg.burst),
+		}
+	}
+	l.clients[ip].lastSeen = time.Now()
+	return l.clients[ip].limiter.Allow()
+}
+
+func (app *application) rateLimitWith(cfg limiterConfig, next http.Handler) http.Handler {
+	limiter := newClientLimiter(cfg)
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if app.config.limiter.enabled {
+		if cfg.enabled {
 			ip, _, err := net.SplitHostPort(r.RemoteAddr)
 			if err != nil {
 				app.serverErrorResponse(w, r, err)
 				return
 			}
+			if !limiter.allow(ip) {
+				app.rateLimitExceedResponse(w, r)
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+type runtimeFormatWriter struct {
+	http.ResponseWriter
+	format data.RuntimeFormat
+}
+
+func (w *runtimeFormatWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func (app *application) runtimeFormat(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Runtime-Format")
+		format := r.URL.Query().Get("runtime_format")
+		if format == "" {
+			format = r.Header.Get("Runtime-Format")
+		}
+		if format == "" || format == string(data.RuntimeFormatMins) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		v := validator.New()
+		v.Check(validator.In(format, data.RuntimeFormats...), "runtime_format", "must be one of: mins, minutes, iso8601, human")
+		if !v.Valid() {
+			app.failedValidationResponse(w, r, v.Errors)
+			return
+		}
+		next.ServeHTTP(&runtimeFormatWriter{ResponseWriter: w, format: data.RuntimeFormat(format)}, r)
+	})
+}
+
+type negotiatedWriter struct {
+	http.ResponseWriter
+	encoders []negotiatedEncoder
+}
+
+func (w *negotiatedWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
 
-			mu.Lock()
-			if _, found := clients[ip]; !found {
-				clients[ip] = &client{
-					limiter: rate.NewLimiter(rate.Limit(app.config.limiter.rps), app.config.limiter.burst),
+// negotiate picks the response encoders from the Accept header. Requests
+// that may change state are refused up front; safe requests only fail when
+// they produce an envelope, so image and event stream routes are unaffected.
+func (app *application) negotiate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept")
+		nw := &negotiatedWriter{ResponseWriter: w, encoders: negotiateEncoders(r.Header.Get("Accept"))}
+		if len(nw.encoders) == 0 && r.Method != http.MethodGet && r.Method != http.MethodHead {
+			app.notAcceptableResponse(nw, r)
+			return
+		}
+		next.ServeHTTP(nw, r)
+	})
+}
+
+type idempotencyRecorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (w *idempotencyRecorder) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *idempotencyRecorder) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *idempotencyRecorder) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func (app *application) idempotent(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get("Idempotency-Key")
+		if r.Method != http.MethodPost || key == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if len(key) > 255 {
+			app.badRequestResponse(w, r, errors.New("Idempotency-Key header must not be more than 255 bytes long"))
+			return
+		}
+		// Keys are chosen by clients, so one client must not be able to
+		// replay or block another's by guessing its key.
+		ip, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+		key = ip + " " + key
+		limit := app.config.images.maxSize + 1_024*1_024
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, limit))
+		if err != nil {
+			app.badRequestResponse(w, r, fmt.Errorf("body must not be larger than %d bytes", limit))
+			return
+		}
+		// The recorded response is already encoded for the caller, so the
+		// headers that select its representation are part of the request.
+		hash := sha256.New()
+		fmt.Fprintf(hash, "%s %s\n", r.Method, r.URL.RequestURI())
+		for _, name := range []string{"Accept", "Accept-Language", "Runtime-Format"} {
+			fmt.Fprintf(hash, "%s: %s\n", name, strings.Join(r.Header.Values(name), ", "))
+		}
+		hash.Write(body)
+		fingerprint := hash.Sum(nil)
+
+		record, err := app.models.IdempotencyKeys.Begin(key, fingerprint, app.config.idempotency.ttl, app.config.idempotency.lockTimeout)
+		if err != nil {
+			switch {
+			case errors.Is(err, data.ErrRecordNotFound):
+				app.idempotencyKeyInFlightResponse(w, r)
+			default:
+				app.serverErrorResponse(w, r, err)
+			}
+			return
+		}
+		if record != nil {
+			switch {
+			case !record.Matches(fingerprint):
+				app.idempotencyKeyMismatchResponse(w, r)
+			case record.InFlight():
+				app.idempotencyKeyInFlightResponse(w, r)
+			default:
+				for name, values := range record.Header {
+					if name == "Vary" {
+						w.Header()[name] = append(w.Header()[name], values...)
+						continue
+					}
+					w.Header()[name] = values
 				}
+				w.Header().Set("Idempotent-Replayed", "true")
+				w.WriteHeader(record.Status)
+				w.Write(record.Body)
 			}
-			clients[ip].lastSeen = time.Now()
+			return
+		}
 
-			if !clients[ip].limiter.Allow() {
-				mu.Unlock()
-				app.rateLimitExceedResponse(w, r)
-				return
+		completed := false
+		defer func() {
+			if !completed {
+				err := app.models.IdempotencyKeys.Release(key, fingerprint)
+				if err != nil {
+					app.logError(r, err)
+				}
 			}
-			mu.Unlock()
+		}()
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		outerVary := w.Header().Values("Vary")
+		rec := &idempotencyRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r)
+		if rec.status == 0 || rec.status >= http.StatusInternalServerError {
+			return
+		}
+		completed = true
+		// The recorded body is the one written before compression. The
+		// headers compress and negotiate put on the shared header map describe
+		// the live response only, and they set them again on a replay.
+		header := w.Header().Clone()
+		header.Del("Content-Encoding")
+		header.Del("Content-Length")
+		header.Del("Vary")
+		if vary := w.Header().Values("Vary"); len(vary) > len(outerVary) {
+			header["Vary"] = slices.Clone(vary[len(outerVary):])
+		}
+		err = app.models.IdempotencyKeys.Complete(&data.IdempotencyRecord{
+			Key:         key,
+			Fingerprint: fingerprint,
+			Status:      rec.status,
+			Header:      header,
+			Body:        rec.body.Bytes(),
+		})
+		if err != nil {
+			app.logError(r, err)
 		}
-		next.ServeHTTP(w, r)
 	})
 }
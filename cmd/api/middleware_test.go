@@ -0,0 +1,137 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/noonacedia/cinematrique/internal/data"
+)
+
+func TestRuntimeFormat(t *testing.T) {
+	app := newTestApplication(t)
+	h := app.runtimeFormat(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		app.writeJSON(w, http.StatusOK, envelope{
+			"movie":   &data.Movie{ID: 1, Title: "Alien", Runtime: 117},
+			"webhook": map[string]string{"runtime": "5 mins"},
+		}, nil)
+	}))
+	tests := []struct {
+		name       string
+		target     string
+		header     http.Header
+		wantStatus int
+		want       string
+	}{
+		{"default", "/", nil, http.StatusOK, `"runtime":"117 mins"`},
+		{"query", "/?runtime_format=iso8601", nil, http.StatusOK, `"runtime":"PT1H57M"`},
+		{"header", "/", http.Header{"Runtime-Format": {"human"}}, http.StatusOK, `"runtime":"1h 57m"`},
+		{"minutes", "/?runtime_format=minutes", nil, http.StatusOK, `"runtime":117`},
+		{"unknown", "/?runtime_format=fortnights", nil, http.StatusUnprocessableEntity, `"runtime_format"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := serve(t, h, http.MethodGet, tt.target, "", tt.header)
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", rr.Code, tt.wantStatus, rr.Body)
+			}
+			body := rr.Body.String()
+			if !strings.Contains(body, tt.want) {
+				t.Errorf("body %s does not contain %s", body, tt.want)
+			}
+			if rr.Code == http.StatusOK && !strings.Contains(body, `"runtime":"5 mins"`) {
+				t.Errorf("body %s rewrote a runtime key that is not a data.Runtime", body)
+			}
+		})
+	}
+}
+
+func TestIdempotent(t *testing.T) {
+	app := newTestApplication(t)
+	keys := newMemoryIdempotencyKeys()
+	app.models.IdempotencyKeys = keys
+	calls := 0
+	h := app.negotiate(app.idempotent(app.runtimeFormat(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		app.writeJSON(w, http.StatusCreated, envelope{"movie": &data.Movie{ID: int64(calls), Title: "Alien", Runtime: 117}}, nil)
+	}))))
+	post := func(key, body string, header http.Header) *httptest.ResponseRecorder {
+		t.Helper()
+		if header == nil {
+			header = make(http.Header)
+		}
+		header.Set("Idempotency-Key", key)
+		return serve(t, h, http.MethodPost, "/v1/movies", body, header)
+	}
+
+	first := post("a", `{"title":"Alien"}`, nil)
+	if first.Code != http.StatusCreated {
+		t.Fatalf("first status = %d, want %d", first.Code, http.StatusCreated)
+	}
+	replay := post("a", `{"title":"Alien"}`, nil)
+	switch {
+	case replay.Code != http.StatusCreated:
+		t.Errorf("replay status = %d, want %d", replay.Code, http.StatusCreated)
+	case replay.Header().Get("Idempotent-Replayed") != "true":
+		t.Error("replay is missing Idempotent-Replayed")
+	case replay.Body.String() != first.Body.String():
+		t.Errorf("replay body = %s, want %s", replay.Body, first.Body)
+	case calls != 1:
+		t.Errorf("handler ran %d times, want 1", calls)
+	}
+
+	// The same key from another client is a new request, not a replay.
+	r := httptest.NewRequest(http.MethodPost, "/v1/movies", strings.NewReader(`{"title":"Alien"}`))
+	r.RemoteAddr = "198.51.100.7:4321"
+	r.Header.Set("Idempotency-Key", "a")
+	other := httptest.NewRecorder()
+	h.ServeHTTP(other, r)
+	switch {
+	case other.Code != http.StatusCreated:
+		t.Errorf("other client status = %d, want %d", other.Code, http.StatusCreated)
+	case other.Header().Get("Idempotent-Replayed") != "":
+		t.Error("other client was replayed the first client's response")
+	case calls != 2:
+		t.Errorf("handler ran %d times, want 2", calls)
+	}
+
+	mismatches := []struct {
+		name   string
+		body   string
+		header http.Header
+	}{
+		{"body", `{"title":"Aliens"}`, nil},
+		{"accept", `{"title":"Alien"}`, http.Header{"Accept": {"text/csv"}}},
+		{"runtime format", `{"title":"Alien"}`, http.Header{"Runtime-Format": {"iso8601"}}},
+	}
+	for _, tt := range mismatches {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := post("a", tt.body, tt.header)
+			if rr.Code != http.StatusUnprocessableEntity {
+				t.Errorf("status = %d, want %d: %s", rr.Code, http.StatusUnprocessableEntity, rr.Body)
+			}
+		})
+	}
+
+	started, release := make(chan struct{}), make(chan struct{})
+	slow := app.idempotent(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		w.WriteHeader(http.StatusCreated)
+	}))
+	header := http.Header{"Idempotency-Key": {"b"}}
+	done := make(chan int)
+	go func() {
+		done <- serve(t, slow, http.MethodPost, "/v1/movies", `{"title":"Alien"}`, header).Code
+	}()
+	<-started
+	inFlight := serve(t, slow, http.MethodPost, "/v1/movies", `{"title":"Alien"}`, header)
+	close(release)
+	if inFlight.Code != http.StatusConflict {
+		t.Errorf("in-flight status = %d, want %d", inFlight.Code, http.StatusConflict)
+	}
+	if code := <-done; code != http.StatusCreated {
+		t.Errorf("original status = %d, want %d", code, http.StatusCreated)
+	}
+}
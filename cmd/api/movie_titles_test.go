@@ -0,0 +1,102 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	"github.com/noonacedia/cinematrique/internal/data"
+)
+
+func TestReadAcceptLanguage(t *testing.T) {
+	tests := []struct {
+		header string
+		want   []string
+	}{
+		{"", []string{}},
+		{"*", []string{}},
+		{"en", []string{"en"}},
+		{"pt-BR", []string{"pt-br", "pt"}},
+		{"pt-BR, en;q=0.8", []string{"pt-br", "pt", "en"}},
+		{"pt-BR, pt-PT;q=0.9, en;q=0.8", []string{"pt-br", "pt-pt", "pt", "en"}},
+		{"pt-BR, en;q=0.8, pt;q=0.5", []string{"pt-br", "en", "pt"}},
+		{"pt, pt-BR;q=0.5", []string{"pt", "pt-br"}},
+		{"en;q=0.5, de", []string{"de", "en"}},
+		{"zh-Hant-TW", []string{"zh-hant-tw", "zh"}},
+		{"fr;q=0, it", []string{"it"}},
+		{"fr;q=abc, it", []string{"it"}},
+		{"EN-gb, en-GB;q=0.3", []string{"en-gb", "en"}},
+	}
+	app := newTestApplication(t)
+	for _, tt := range tests {
+		t.Run(tt.header, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.Header.Set("Accept-Language", tt.header)
+			if got := app.readAcceptLanguage(r); !slices.Equal(got, tt.want) {
+				t.Errorf("locales = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// localizedTitles picks the first requested locale with a stored title, as
+// GetDisplayTitles does in SQL.
+type localizedTitles struct {
+	data.MockMovieTitleModel
+	titles map[string]string
+}
+
+func (m localizedTitles) GetDisplayTitles(movieIDs []int64, locales []string) (map[int64]*data.MovieTitle, error) {
+	found := make(map[int64]*data.MovieTitle)
+	for _, id := range movieIDs {
+		for _, locale := range locales {
+			if title, ok := m.titles[locale]; ok {
+				found[id] = &data.MovieTitle{MovieID: id, Locale: locale, Kind: "localized", Title: title}
+				break
+			}
+		}
+	}
+	return found, nil
+}
+
+func TestLocalizeMovies(t *testing.T) {
+	tests := []struct {
+		name         string
+		header       string
+		wantTitle    string
+		wantOriginal string
+		wantLanguage string
+	}{
+		{"no preference", "", "City of God", "", ""},
+		{"exact match", "pt-PT", "Cidade de Deus (PT)", "City of God", "pt-pt"},
+		{"base language fallback", "pt-BR", "Cidade de Deus", "City of God", "pt"},
+		{"later preference", "ja, pt-PT;q=0.5", "Cidade de Deus (PT)", "City of God", "pt-pt"},
+		{"no stored title", "ja", "City of God", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(t)
+			app.models.MovieTitles = localizedTitles{titles: map[string]string{"pt": "Cidade de Deus", "pt-pt": "Cidade de Deus (PT)"}}
+			movie := &data.Movie{ID: 1, Title: "City of God"}
+			r := httptest.NewRequest(http.MethodGet, "/v1/movies/1", nil)
+			if tt.header != "" {
+				r.Header.Set("Accept-Language", tt.header)
+			}
+			rr := httptest.NewRecorder()
+			err := app.localizeMovies(rr, r, movie)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if movie.Title != tt.wantTitle || movie.OriginalTitle != tt.wantOriginal {
+				t.Errorf("title = %q (original %q), want %q (original %q)", movie.Title, movie.OriginalTitle, tt.wantTitle, tt.wantOriginal)
+			}
+			if got := rr.Header().Get("Content-Language"); got != tt.wantLanguage {
+				t.Errorf("Content-Language = %q, want %q", got, tt.wantLanguage)
+			}
+			if !slices.Contains(rr.Header().Values("Vary"), "Accept-Language") {
+				t.Error("Vary is missing Accept-Language")
+			}
+		})
+	}
+}
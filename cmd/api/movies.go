@@ -4,40 +4,124 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"slices"
 	"strconv"
+	"strings"
 
 	"github.com/noonacedia/cinematrique/internal/data"
 	"github.com/noonacedia/cinematrique/internal/validator"
 )
 
-func (app *application) createMovieHandler(w http.ResponseWriter, r *http.Request) {
-	var input struct {
-		Title   string       `json:"title"`
-		Year    int32        `json:"year"`
-		Runtime data.Runtime `json:"runtime"`
-		Genres  []string     `json:"genres"`
+type movieInput struct {
+	Type        string            `json:"type"`
+	Title       string            `json:"title"`
+	Year        int32             `json:"year"`
+	Runtime     data.Runtime      `json:"runtime"`
+	Genres      []string          `json:"genres"`
+	ExternalIDs map[string]string `json:"external_ids"`
+	Releases    []*data.Release   `json:"releases"`
+}
+
+func (input movieInput) movie() *data.Movie {
+	movie := &data.Movie{
+		Type:        input.Type,
+		Title:       input.Title,
+		Year:        input.Year,
+		Runtime:     input.Runtime,
+		Genres:      input.Genres,
+		ExternalIDs: input.ExternalIDs,
+		Releases:    input.Releases,
+	}
+	if movie.Type == "" {
+		movie.Type = "movie"
+	}
+	for _, release := range movie.Releases {
+		// A null entry is left for ValidateMovie to reject.
+		if release != nil {
+			release.Country = strings.ToUpper(release.Country)
+		}
+	}
+	if movie.Year == 0 {
+		movie.Year = data.EarliestReleaseYear(movie.Releases)
+	}
+	return movie
+}
+
+type moviePatch struct {
+	Title       *string            `json:"title"`
+	Year        *int32             `json:"year"`
+	Runtime     *data.Runtime      `json:"runtime"`
+	Genres      []string           `json:"genres"`
+	ExternalIDs map[string]*string `json:"external_ids"`
+}
+
+func (input moviePatch) apply(movie *data.Movie) {
+	if input.Title != nil {
+		movie.Title = *input.Title
+	}
+	if input.Year != nil {
+		movie.Year = *input.Year
+	}
+	if input.Runtime != nil {
+		movie.Runtime = *input.Runtime
+	}
+	if input.Genres != nil {
+		movie.Genres = input.Genres
+	}
+	if input.ExternalIDs != nil {
+		if movie.ExternalIDs == nil {
+			movie.ExternalIDs = make(map[string]string)
+		}
+		for source, value := range input.ExternalIDs {
+			if value == nil {
+				delete(movie.ExternalIDs, source)
+			} else {
+				movie.ExternalIDs[source] = *value
+			}
+		}
 	}
+}
+
+func (app *application) createMovieHandler(w http.ResponseWriter, r *http.Request) {
+	var input movieInput
 	err := app.readJSON(w, r, &input)
 	if err != nil {
 		app.badRequestResponse(w, r, err)
 		return
 	}
-	movie := &data.Movie{
-		Title:   input.Title,
-		Year:    input.Year,
-		Runtime: input.Runtime,
-		Genres:  input.Genres,
-	}
+	movie := input.movie()
 	v := validator.New()
+	force := app.readBool(r.URL.Query(), "force", false, v)
 
 	if data.ValidateMovie(v, movie); !v.Valid() {
 		app.failedValidationResponse(w, r, v.Errors)
 		return
 	}
 
+	if !force {
+		candidates, err := app.models.Movies.FindDuplicates(movie.Title, movie.Year)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+		if len(candidates) > 0 {
+			app.duplicateMovieResponse(w, r, candidates)
+			return
+		}
+	}
+
 	err = app.models.Movies.Insert(movie)
 	if err != nil {
-		app.serverErrorResponse(w, r, err)
+		var duplicate *data.DuplicateExternalIDError
+		switch {
+		case errors.As(err, &duplicate):
+			app.duplicateExternalIDResponse(w, r, duplicate)
+		case errors.Is(err, data.ErrDuplicateRelease):
+			v.AddError("releases", "must not contain more than one release per country and type")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
 		return
 	}
 	headers := make(http.Header)
@@ -50,51 +134,225 @@ func (app *application) createMovieHandler(w http.ResponseWriter, r *http.Reques
 
 func (app *application) listMoviesHandler(w http.ResponseWriter, r *http.Request) {
 	var input struct {
-		Title  string
-		Genres []string
+		data.MovieSearch
 		data.Filters
 	}
 	v := validator.New()
 	qs := r.URL.Query()
 	input.Title = app.readString(qs, "title", "")
+	input.Type = app.readString(qs, "type", "")
 	input.Genres = app.readCSV(qs, "genres", []string{})
+	input.ReleasedIn = strings.ToUpper(app.readString(qs, "released_in", ""))
+	input.ReleasedAfter = app.readDate(qs, "released_after", v)
+	input.ReleasedBefore = app.readDate(qs, "released_before", v)
 	input.Page = app.readInt(qs, "page", 1, v)
 	input.PageSize = app.readInt(qs, "page_size", 20, v)
-	input.Sort = app.readString(qs, "sort", "id")
-	input.SortSafelist = []string{"id", "-id", "title", "-title", "year", "-year", "runtime", "-runtime"}
+	defaultSort := "id"
+	if input.Title != "" {
+		defaultSort = "relevance"
+	}
+	input.Sort = app.readString(qs, "sort", defaultSort)
+	input.SortSafelist = []string{"id", "-id", "title", "-title", "year", "-year", "runtime", "-runtime", "relevance"}
+	input.UseCursor = qs.Has("cursor")
+	input.Cursor = qs.Get("cursor")
+	input.SkipTotal = !app.readBool(qs, "include_total", true, v)
+	input.Fields = app.readFieldList(qs, "fields", data.MovieFields, v)
+	include := app.readFieldList(qs, "include", app.movieRelationNames(), v)
 	data.ValidateFilters(v, input.Filters)
+	v.Check(input.Sort != "relevance" || input.Title != "", "sort", "relevance is only valid with a title query")
+	v.Check(input.ReleasedIn == "" || validator.Matches(input.ReleasedIn, data.CountryRx), "released_in", "must be an ISO 3166-1 alpha-2 country code")
+	v.Check(input.Type == "" || validator.In(input.Type, data.MovieTypes...), "type", "must be one of: "+strings.Join(data.MovieTypes, ", "))
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+	movies, metadata, err := app.models.Movies.GetAll(input.MovieSearch, input.Filters)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrInvalidCursor):
+			v.AddError("cursor", "must be a valid cursor for the requested sort")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	err = app.localizeMovies(w, r, movies...)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	if len(input.Fields) == 0 || slices.Contains(input.Fields, "images") {
+		err = app.attachImages(movies...)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+	}
+	var payload any = movies
+	if len(input.Fields) > 0 || len(include) > 0 {
+		payload, err = app.projectMovies(movies, input.Fields, include, requestedRuntimeFormat(w))
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+	}
+	err = app.writeJSON(w, http.StatusOK, envelope{"movies": payload, "metadata": metadata}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) suggestMoviesHandler(w http.ResponseWriter, r *http.Request) {
+	v := validator.New()
+	qs := r.URL.Query()
+	prefix := strings.TrimSpace(app.readString(qs, "q", ""))
+	limit := app.readInt(qs, "limit", app.config.suggest.limit, v)
+	v.Check(prefix != "", "q", "must be provided")
+	v.Check(len(prefix) <= 100, "q", "must not be more than 100 bytes long")
+	v.Check(limit > 0, "limit", "must be positive")
+	v.Check(limit <= 20, "limit", "must not be more than 20")
 	if !v.Valid() {
 		app.failedValidationResponse(w, r, v.Errors)
 		return
 	}
-	movies, metadata, err := app.models.Movies.GetAll(input.Title, input.Genres, input.Filters)
+	suggestions, err := app.models.Movies.Suggest(prefix, limit)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
+		return
 	}
-	err = app.writeJSON(w, http.StatusOK, envelope{"movies": movies, "metadata": metadata}, nil)
+	headers := make(http.Header)
+	headers.Set("Cache-Control", "public, max-age=60")
+	err = app.writeJSON(w, http.StatusOK, envelope{"suggestions": suggestions}, headers)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 	}
 }
 
-func (app *application) showMovieHandler(w http.ResponseWriter, r *http.Request) {
+func (app *application) listSimilarMoviesHandler(w http.ResponseWriter, r *http.Request) {
 	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+	v := validator.New()
+	limit := app.readInt(r.URL.Query(), "limit", 10, v)
+	v.Check(limit > 0, "limit", "must be positive")
+	v.Check(limit <= 50, "limit", "must not be more than 50")
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+	_, err = app.models.Movies.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	movies, err := app.models.Movies.GetSimilar(id, limit)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 		return
 	}
-	movie, err := app.models.Movies.Get(id)
+	err = app.localizeMovies(w, r, movies...)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	err = app.writeJSON(w, http.StatusOK, envelope{"similar": movies}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) lookupMovieHandler(w http.ResponseWriter, r *http.Request) {
+	v := validator.New()
+	qs := r.URL.Query()
+	source := app.readString(qs, "source", "")
+	value := app.readString(qs, "id", "")
+	v.Check(source != "", "source", "must be provided")
+	v.Check(value != "", "id", "must be provided")
+	v.Check(source == "" || validator.In(source, data.ExternalIDSourceNames()...), "source", "must be one of: "+strings.Join(data.ExternalIDSourceNames(), ", "))
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+	movie, err := app.models.Movies.GetByExternalID(source, value)
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrRecordNotFound):
 			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	headers := make(http.Header)
+	headers.Set("Content-Location", fmt.Sprintf("/v1/movies/%d", movie.ID))
+	err = app.writeJSON(w, http.StatusOK, envelope{"movie": movie}, headers)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) showMovieHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	v := validator.New()
+	qs := r.URL.Query()
+	fields := app.readFieldList(qs, "fields", data.MovieFields, v)
+	include := app.readFieldList(qs, "include", app.movieRelationNames(), v)
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+	movie, err := app.models.Movies.GetFields(id, fields)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.redirectMergedMovie(w, r, id)
 			return
 		default:
 			app.serverErrorResponse(w, r, err)
 			return
 		}
 	}
-	err = app.writeJSON(w, http.StatusOK, envelope{"movie": movie}, nil)
+	err = app.localizeMovies(w, r, movie)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	if len(fields) == 0 || slices.Contains(fields, "images") {
+		err = app.attachImages(movie)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+	}
+	if len(fields) == 0 || slices.Contains(fields, "collection") {
+		err = app.attachCollection(movie)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+	}
+	var payload any = movie
+	if len(fields) > 0 || len(include) > 0 {
+		projected, err := app.projectMovies([]*data.Movie{movie}, fields, include, requestedRuntimeFormat(w))
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+		payload = projected[0]
+	}
+	err = app.writeJSON(w, http.StatusOK, envelope{"movie": payload}, nil)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 	}
@@ -110,36 +368,20 @@ func (app *application) updateMovieHandler(w http.ResponseWriter, r *http.Reques
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrRecordNotFound):
-			app.notFoundResponse(w, r)
+			app.redirectMergedMovie(w, r, id)
 			return
 		default:
 			app.serverErrorResponse(w, r, err)
 			return
 		}
 	}
-	var input struct {
-		Title   *string       `json:"title"`
-		Year    *int32        `json:"year"`
-		Runtime *data.Runtime `json:"runtime"`
-		Genres  []string      `json:"genres"`
-	}
+	var input moviePatch
 	err = app.readJSON(w, r, &input)
 	if err != nil {
 		app.badRequestResponse(w, r, err)
 		return
 	}
-	if input.Title != nil {
-		movie.Title = *input.Title
-	}
-	if input.Year != nil {
-		movie.Year = *input.Year
-	}
-	if input.Runtime != nil {
-		movie.Runtime = *input.Runtime
-	}
-	if input.Genres != nil {
-		movie.Genres = input.Genres
-	}
+	input.apply(movie)
 	v := validator.New()
 	if data.ValidateMovie(v, movie); !v.Valid() {
 		app.failedValidationResponse(w, r, v.Errors)
@@ -147,9 +389,12 @@ func (app *application) updateMovieHandler(w http.ResponseWriter, r *http.Reques
 	}
 	err = app.models.Movies.Update(movie)
 	if err != nil {
+		var duplicate *data.DuplicateExternalIDError
 		switch {
 		case errors.Is(err, data.ErrEditConflict):
 			app.editConflictResponse(w, r)
+		case errors.As(err, &duplicate):
+			app.duplicateExternalIDResponse(w, r, duplicate)
 		default:
 			app.serverErrorResponse(w, r, err)
 		}
@@ -162,17 +407,83 @@ func (app *application) updateMovieHandler(w http.ResponseWriter, r *http.Reques
 	}
 }
 
-func (app *application) removeMovieHandler(w http.ResponseWriter, r *http.Request) {
+func (app *application) mergeMovieHandler(w http.ResponseWriter, r *http.Request) {
 	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+	var input struct {
+		DuplicateID int64 `json:"duplicate_id"`
+	}
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	v := validator.New()
+	v.Check(input.DuplicateID > 0, "duplicate_id", "must be provided")
+	v.Check(input.DuplicateID != id, "duplicate_id", "must differ from the surviving movie")
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+	err = app.models.Movies.Merge(id, input.DuplicateID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	movie, err := app.models.Movies.Get(id)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 		return
 	}
-	err = app.models.Movies.Delete(id)
+	err = app.writeJSON(w, http.StatusOK, envelope{"movie": movie}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) redirectMergedMovie(w http.ResponseWriter, r *http.Request, id int64) {
+	target, err := app.models.Movies.GetRedirect(id)
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrRecordNotFound):
 			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	location := fmt.Sprintf("/v1/movies/%d", target)
+	if r.URL.RawQuery != "" {
+		location += "?" + r.URL.RawQuery
+	}
+	// 301 lets clients rewrite a PATCH or DELETE into a GET, so only safe
+	// methods use it; everything else gets the method-preserving 308.
+	status := http.StatusPermanentRedirect
+	if r.Method == http.MethodGet || r.Method == http.MethodHead {
+		status = http.StatusMovedPermanently
+	}
+	http.Redirect(w, r, location, status)
+}
+
+func (app *application) removeMovieHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	err = app.deleteMovie(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.redirectMergedMovie(w, r, id)
 			return
 		default:
 			app.serverErrorResponse(w, r, err)
@@ -0,0 +1,346 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/noonacedia/cinematrique/internal/data"
+)
+
+func TestListMoviesRelevanceSort(t *testing.T) {
+	app := newTestApplication(t)
+	tests := []struct {
+		name       string
+		target     string
+		wantStatus int
+		wantError  string
+	}{
+		{"relevance needs a title", "/v1/movies?sort=relevance", http.StatusUnprocessableEntity, "relevance is only valid with a title query"},
+		{"relevance with a title", "/v1/movies?title=alien&sort=relevance", http.StatusOK, ""},
+		{"title defaults to relevance", "/v1/movies?title=alien", http.StatusOK, ""},
+		{"title with another sort", "/v1/movies?title=alien&sort=-year", http.StatusOK, ""},
+		{"unknown sort", "/v1/movies?sort=score", http.StatusUnprocessableEntity, "invalid sort value"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := serve(t, http.HandlerFunc(app.listMoviesHandler), http.MethodGet, tt.target, "", nil)
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", rr.Code, tt.wantStatus, rr.Body)
+			}
+			if tt.wantError == "" {
+				return
+			}
+			var body struct {
+				Error map[string]string `json:"error"`
+			}
+			err := json.Unmarshal(rr.Body.Bytes(), &body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if body.Error["sort"] != tt.wantError {
+				t.Errorf("sort error = %q, want %q", body.Error["sort"], tt.wantError)
+			}
+		})
+	}
+}
+
+// mergedMovies reports movie 7 as merged into movie 3.
+type mergedMovies struct {
+	data.MockMovieModel
+}
+
+func (m mergedMovies) Get(id int64) (*data.Movie, error) {
+	return nil, data.ErrRecordNotFound
+}
+
+func (m mergedMovies) GetFields(id int64, fields []string) (*data.Movie, error) {
+	return nil, data.ErrRecordNotFound
+}
+
+func (m mergedMovies) Delete(id int64) error {
+	return data.ErrRecordNotFound
+}
+
+func (m mergedMovies) GetRedirect(id int64) (int64, error) {
+	if id == 7 {
+		return 3, nil
+	}
+	return 0, data.ErrRecordNotFound
+}
+
+func TestMergedMovieRedirects(t *testing.T) {
+	app := newTestApplication(t)
+	app.models.Movies = mergedMovies{}
+	tests := []struct {
+		method       string
+		target       string
+		body         string
+		wantStatus   int
+		wantLocation string
+	}{
+		{http.MethodGet, "/v1/movies/7?fields=title", "", http.StatusMovedPermanently, "/v1/movies/3?fields=title"},
+		{http.MethodPatch, "/v1/movies/7", `{"title":"Alien"}`, http.StatusPermanentRedirect, "/v1/movies/3"},
+		{http.MethodDelete, "/v1/movies/7", "", http.StatusPermanentRedirect, "/v1/movies/3"},
+		{http.MethodPatch, "/v1/movies/8", `{"title":"Alien"}`, http.StatusNotFound, ""},
+		{http.MethodDelete, "/v1/movies/8", "", http.StatusNotFound, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.target, func(t *testing.T) {
+			rr := serve(t, app.routes(), tt.method, tt.target, tt.body, nil)
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", rr.Code, tt.wantStatus, rr.Body)
+			}
+			if got := rr.Header().Get("Location"); got != tt.wantLocation {
+				t.Errorf("Location = %q, want %q", got, tt.wantLocation)
+			}
+		})
+	}
+}
+
+func TestNullReleaseIsRejected(t *testing.T) {
+	app := newTestApplication(t)
+	body := `{"title":"Alien","runtime":117,"genres":["horror"],"releases":[null]}`
+	rr := serve(t, app.routes(), http.MethodPost, "/v1/movies", body, nil)
+	if rr.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("status = %d, want %d: %s", rr.Code, http.StatusUnprocessableEntity, rr.Body)
+	}
+	var res struct {
+		Error map[string]string `json:"error"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &res); err != nil {
+		t.Fatal(err)
+	}
+	if res.Error["releases[0]"] != "must not be null" {
+		t.Errorf("errors = %v, want releases[0] to be rejected", res.Error)
+	}
+
+	var input movieInput
+	if err := json.Unmarshal([]byte(body), &input); err != nil {
+		t.Fatal(err)
+	}
+	_, err := app.runBatchOperation(nil, &batchOperation{Op: "create", Movie: &input})
+	var failure *batchFailure
+	if !errors.As(err, &failure) || failure.status != http.StatusUnprocessableEntity {
+		t.Errorf("batch create returned %v, want a 422 failure", err)
+	}
+}
+
+// recordedSuggestions remembers the arguments of the last Suggest call.
+type recordedSuggestions struct {
+	data.MockMovieModel
+	prefix *string
+	limit  *int
+	err    error
+}
+
+func (m recordedSuggestions) Suggest(prefix string, limit int) ([]*data.MovieSuggestion, error) {
+	*m.prefix, *m.limit = prefix, limit
+	if m.err != nil {
+		return nil, m.err
+	}
+	return []*data.MovieSuggestion{{ID: 1, Title: "Alien", Year: 1979}}, nil
+}
+
+func TestSuggestMovies(t *testing.T) {
+	tests := []struct {
+		name       string
+		target     string
+		err        error
+		wantStatus int
+		wantPrefix string
+		wantLimit  int
+		wantError  map[string]string
+	}{
+		{"default limit", "/v1/movies/suggest?q=+ali+", nil, http.StatusOK, "ali", 10, nil},
+		{"explicit limit", "/v1/movies/suggest?q=ali&limit=20", nil, http.StatusOK, "ali", 20, nil},
+		{"missing prefix", "/v1/movies/suggest?q=+", nil, http.StatusUnprocessableEntity, "", 0, map[string]string{"q": "must be provided"}},
+		{"long prefix", "/v1/movies/suggest?q=" + strings.Repeat("a", 101), nil, http.StatusUnprocessableEntity, "", 0, map[string]string{"q": "must not be more than 100 bytes long"}},
+		{"zero limit", "/v1/movies/suggest?q=ali&limit=0", nil, http.StatusUnprocessableEntity, "", 0, map[string]string{"limit": "must be positive"}},
+		{"large limit", "/v1/movies/suggest?q=ali&limit=21", nil, http.StatusUnprocessableEntity, "", 0, map[string]string{"limit": "must not be more than 20"}},
+		{"timeout", "/v1/movies/suggest?q=ali", context.DeadlineExceeded, http.StatusInternalServerError, "ali", 10, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(t)
+			app.config.suggest.limit = 10
+			var prefix string
+			var limit int
+			app.models.Movies = recordedSuggestions{prefix: &prefix, limit: &limit, err: tt.err}
+
+			rr := serve(t, http.HandlerFunc(app.suggestMoviesHandler), http.MethodGet, tt.target, "", nil)
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", rr.Code, tt.wantStatus, rr.Body)
+			}
+			if prefix != tt.wantPrefix || limit != tt.wantLimit {
+				t.Errorf("Suggest(%q, %d), want Suggest(%q, %d)", prefix, limit, tt.wantPrefix, tt.wantLimit)
+			}
+			if tt.wantStatus == http.StatusOK && rr.Header().Get("Cache-Control") != "public, max-age=60" {
+				t.Errorf("Cache-Control = %q", rr.Header().Get("Cache-Control"))
+			}
+			if tt.wantError == nil {
+				return
+			}
+			var body struct {
+				Error map[string]string `json:"error"`
+			}
+			err := json.Unmarshal(rr.Body.Bytes(), &body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(body.Error, tt.wantError) {
+				t.Errorf("errors = %v, want %v", body.Error, tt.wantError)
+			}
+		})
+	}
+}
+
+func TestSuggestMoviesHasItsOwnRateLimit(t *testing.T) {
+	app := newTestApplication(t)
+	app.config.suggest.limit = 10
+	app.config.limiter = limiterConfig{rps: 0.001, burst: 1, enabled: true}
+	app.config.suggest.limiter = limiterConfig{rps: 0.001, burst: 3, enabled: true}
+	var prefix string
+	var limit int
+	app.models.Movies = recordedSuggestions{prefix: &prefix, limit: &limit}
+	h := app.routes()
+
+	// The general limiter allows one request, so the suggestions burst must
+	// not be drawn from it.
+	if rr := serve(t, h, http.MethodGet, "/v1/healthcheck", "", nil); rr.Code != http.StatusOK {
+		t.Fatalf("healthcheck status = %d", rr.Code)
+	}
+	for i := range 3 {
+		if rr := serve(t, h, http.MethodGet, "/v1/movies/suggest?q=ali", "", nil); rr.Code != http.StatusOK {
+			t.Fatalf("suggestion %d status = %d, want %d", i+1, rr.Code, http.StatusOK)
+		}
+	}
+	if rr := serve(t, h, http.MethodGet, "/v1/movies/suggest?q=ali", "", nil); rr.Code != http.StatusTooManyRequests {
+		t.Errorf("suggestion over the burst status = %d, want %d", rr.Code, http.StatusTooManyRequests)
+	}
+	if rr := serve(t, h, http.MethodGet, "/v1/healthcheck", "", nil); rr.Code != http.StatusTooManyRequests {
+		t.Errorf("second healthcheck status = %d, want %d", rr.Code, http.StatusTooManyRequests)
+	}
+}
+
+// similarMovies serves one stored movie and remembers the limit of the last
+// GetSimilar call.
+type similarMovies struct {
+	storedMovie
+	limit *int
+}
+
+func (m similarMovies) GetSimilar(id int64, limit int) ([]*data.Movie, error) {
+	*m.limit = limit
+	return []*data.Movie{{ID: 2, Title: "Aliens", Year: 1986, Genres: []string{"sci-fi"}}}, nil
+}
+
+func TestListSimilarMovies(t *testing.T) {
+	tests := []struct {
+		name       string
+		target     string
+		wantStatus int
+		wantLimit  int
+		wantError  map[string]string
+	}{
+		{"default limit", "/v1/movies/1/similar", http.StatusOK, 10, nil},
+		{"explicit limit", "/v1/movies/1/similar?limit=50", http.StatusOK, 50, nil},
+		{"zero limit", "/v1/movies/1/similar?limit=0", http.StatusUnprocessableEntity, 0, map[string]string{"limit": "must be positive"}},
+		{"large limit", "/v1/movies/1/similar?limit=51", http.StatusUnprocessableEntity, 0, map[string]string{"limit": "must not be more than 50"}},
+		{"non-numeric limit", "/v1/movies/1/similar?limit=ten", http.StatusUnprocessableEntity, 0, map[string]string{"limit": "must be an integer type"}},
+		{"unknown movie", "/v1/movies/3/similar", http.StatusNotFound, 0, nil},
+		{"malformed id", "/v1/movies/abc/similar", http.StatusNotFound, 0, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(t)
+			var limit int
+			app.models.Movies = similarMovies{
+				storedMovie: storedMovie{movie: &data.Movie{ID: 1, Title: "Alien", Year: 1979, Genres: []string{"sci-fi"}}},
+				limit:       &limit,
+			}
+
+			rr := serve(t, app.routes(), http.MethodGet, tt.target, "", nil)
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", rr.Code, tt.wantStatus, rr.Body)
+			}
+			if limit != tt.wantLimit {
+				t.Errorf("GetSimilar limit = %d, want %d", limit, tt.wantLimit)
+			}
+			if tt.wantStatus == http.StatusOK {
+				var body struct {
+					Similar []data.Movie `json:"similar"`
+				}
+				err := json.Unmarshal(rr.Body.Bytes(), &body)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(body.Similar) != 1 || body.Similar[0].ID != 2 {
+					t.Errorf("similar = %+v, want movie 2", body.Similar)
+				}
+			}
+			if tt.wantError == nil {
+				return
+			}
+			var body struct {
+				Error map[string]string `json:"error"`
+			}
+			err := json.Unmarshal(rr.Body.Bytes(), &body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(body.Error, tt.wantError) {
+				t.Errorf("errors = %v, want %v", body.Error, tt.wantError)
+			}
+		})
+	}
+}
+
+func TestRuntimeFormatWithProjection(t *testing.T) {
+	tests := []struct {
+		name   string
+		target string
+		want   any
+	}{
+		{"fields", "/v1/movies/1?fields=runtime&runtime_format=iso8601", "PT1H57M"},
+		{"include", "/v1/movies/1?include=titles&runtime_format=iso8601", "PT1H57M"},
+		{"header", "/v1/movies/1?fields=title,runtime", "PT1H57M"},
+		{"default format", "/v1/movies/1?fields=runtime", "117 mins"},
+		{"list fields", "/v1/movies?fields=id,runtime&runtime_format=iso8601", "PT1H57M"},
+		{"list include", "/v1/movies?include=releases&runtime_format=iso8601", "PT1H57M"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(t)
+			app.models.Movies = storedMovie{movie: &data.Movie{ID: 1, Type: "movie", Title: "Alien", Year: 1979, Runtime: 117, Genres: []string{"horror"}}}
+			var header http.Header
+			if tt.name == "header" {
+				header = http.Header{"Runtime-Format": {"iso8601"}}
+			}
+
+			rr := serve(t, app.routes(), http.MethodGet, tt.target, "", header)
+			if rr.Code != http.StatusOK {
+				t.Fatalf("status = %d: %s", rr.Code, rr.Body)
+			}
+			var body struct {
+				Movie  map[string]any   `json:"movie"`
+				Movies []map[string]any `json:"movies"`
+			}
+			err := json.Unmarshal(rr.Body.Bytes(), &body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			movie := body.Movie
+			if len(body.Movies) > 0 {
+				movie = body.Movies[0]
+			}
+			if movie["runtime"] != tt.want {
+				t.Errorf("runtime = %v, want %v", movie["runtime"], tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/noonacedia/cinematrique/internal/data"
+)
+
+type changeFanout struct {
+	mu          sync.RWMutex
+	subscribers []func(change data.MovieChange)
+}
+
+func (f *changeFanout) Subscribe(fn func(change data.MovieChange)) {
+	f.mu.Lock()
+	f.subscribers = append(f.subscribers, fn)
+	f.mu.Unlock()
+}
+
+func (f *changeFanout) Broadcast(change data.MovieChange) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	for _, fn := range f.subscribers {
+		fn(change)
+	}
+}
+
+func (app *application) listenMovieChanges() error {
+	listener := pq.NewListener(app.config.db.dsn, time.Second, time.Minute, func(event pq.ListenerEventType, err error) {
+		if err != nil {
+			app.logger.PrintError(err, map[string]string{"listener": data.MovieChangedChannel})
+		}
+	})
+	for _, channel := range []string{data.MovieChangedChannel, data.OutboxPublishedChannel} {
+		err := listener.Listen(channel)
+		if err != nil {
+			listener.Close()
+			return err
+		}
+	}
+	app.runRecoverableBackground(func() {
+		defer listener.Close()
+		ping := time.NewTicker(90 * time.Second)
+		defer ping.Stop()
+		for {
+			select {
+			case <-app.done:
+				return
+			case <-ping.C:
+				go listener.Ping()
+			case n := <-listener.Notify:
+				if n == nil {
+					app.changes.Broadcast(data.MovieChange{Op: data.MovieChangeResync})
+					app.wakeOutboxFollower()
+					continue
+				}
+				if n.Channel == data.OutboxPublishedChannel {
+					app.wakeOutboxFollower()
+					continue
+				}
+				var change data.MovieChange
+				err := json.Unmarshal([]byte(n.Extra), &change)
+				if err != nil {
+					app.logger.PrintError(err, map[string]string{"listener": data.MovieChangedChannel})
+					continue
+				}
+				app.changes.Broadcast(change)
+			}
+		}
+	})
+	return nil
+}
+
+func (app *application) wakeOutboxFollower() {
+	select {
+	case app.outboxWake <- struct{}{}:
+	default:
+	}
+}
+
+// followOutbox feeds the event hub from the published outbox stream. It is
+// woken by the outbox_published notification that every instance receives
+// when the relay commits a batch, and polls as a fallback.
+func (app *application) followOutbox() error {
+	after, err := app.models.Outbox.LatestSeq()
+	if err != nil {
+		return err
+	}
+	app.runRecoverableBackground(func() {
+		ticker := time.NewTicker(app.config.events.pollInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-app.done:
+				return
+			case <-ticker.C:
+			case <-app.outboxWake:
+			}
+			after = app.drainOutbox(after)
+		}
+	})
+	return nil
+}
+
+// drainOutbox publishes every event after the given stream position and
+// returns the position of the last one.
+func (app *application) drainOutbox(after int64) int64 {
+	for {
+		events, err := app.models.Outbox.GetAfter(after, 500)
+		if err != nil {
+			app.logger.PrintError(err, nil)
+			return after
+		}
+		for _, event := range events {
+			app.events.Publish(event)
+			after = event.Seq
+		}
+		if len(events) < 500 {
+			return after
+		}
+	}
+}
+
+type outboxWakeSink struct {
+	app *application
+}
+
+func (s outboxWakeSink) Publish(event *data.OutboxEvent) error {
+	s.app.wakeOutboxFollower()
+	return nil
+}
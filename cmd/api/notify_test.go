@@ -0,0 +1,46 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/noonacedia/cinematrique/internal/data"
+)
+
+func TestDrainOutboxFollowsPublishOrder(t *testing.T) {
+	app := newTestApplication(t)
+	app.events = newEventHub()
+	outbox := &publishedOutbox{events: []*data.OutboxEvent{
+		{ID: 10, Seq: 1, Event: "movie.created"},
+		{ID: 12, Seq: 2, Event: "movie.created"},
+	}}
+	app.models.Outbox = outbox
+	received := app.events.Subscribe(8)
+
+	after := app.drainOutbox(0)
+	// Event 11 committed after 12 and was published later; following ids
+	// instead of the stream position would skip it.
+	outbox.events = append(outbox.events, &data.OutboxEvent{ID: 11, Seq: 3, Event: "movie.updated"})
+	after = app.drainOutbox(after)
+	if after != 3 {
+		t.Errorf("position = %d, want 3", after)
+	}
+	if after = app.drainOutbox(after); after != 3 {
+		t.Errorf("position after an empty drain = %d, want 3", after)
+	}
+
+	for _, want := range []int64{10, 12, 11} {
+		select {
+		case event := <-received:
+			if event.ID != want {
+				t.Fatalf("got event %d, want %d", event.ID, want)
+			}
+		default:
+			t.Fatalf("event %d was not published", want)
+		}
+	}
+	select {
+	case event := <-received:
+		t.Errorf("event %d was published twice", event.ID)
+	default:
+	}
+}
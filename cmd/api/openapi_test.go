@@ -0,0 +1,370 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"mime"
+	"net/http"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/noonacedia/cinematrique/internal/data"
+)
+
+var wildcardSuffix = regexp.MustCompile(`\{(\w+)\.\.\.\}`)
+
+func registeredRoutes(t *testing.T) []string {
+	t.Helper()
+	file, err := parser.ParseFile(token.NewFileSet(), "routes.go", nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var patterns []string
+	ast.Inspect(file, func(n ast.Node) bool {
+		call, ok := n.(*ast.CallExpr)
+		if !ok || len(call.Args) == 0 {
+			return true
+		}
+		sel, ok := call.Fun.(*ast.SelectorExpr)
+		if !ok || (sel.Sel.Name != "Handle" && sel.Sel.Name != "HandleFunc") {
+			return true
+		}
+		lit, ok := call.Args[0].(*ast.BasicLit)
+		if !ok || lit.Kind != token.STRING {
+			return true
+		}
+		pattern, err := strconv.Unquote(lit.Value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		patterns = append(patterns, pattern)
+		return true
+	})
+	return patterns
+}
+
+func TestOpenAPICoversRoutes(t *testing.T) {
+	var spec struct {
+		OpenAPI string                                `json:"openapi"`
+		Paths   map[string]map[string]json.RawMessage `json:"paths"`
+	}
+	err := json.Unmarshal(openapiSpec, &spec)
+	if err != nil {
+		t.Fatalf("openapi.json is not valid JSON: %v", err)
+	}
+	if !strings.HasPrefix(spec.OpenAPI, "3.1") {
+		t.Fatalf("openapi version = %q, want 3.1.x", spec.OpenAPI)
+	}
+
+	routes := registeredRoutes(t)
+	if len(routes) == 0 {
+		t.Fatal("found no routes in routes.go")
+	}
+	for _, pattern := range routes {
+		method, path, ok := strings.Cut(pattern, " ")
+		if !ok {
+			// Catch-all patterns such as "/" only delegate to another mux.
+			continue
+		}
+		path = wildcardSuffix.ReplaceAllString(path, "{$1}")
+		if _, ok := spec.Paths[path][strings.ToLower(method)]; !ok {
+			t.Errorf("route %q is missing from openapi.json", pattern)
+		}
+	}
+}
+
+type openapiDocument map[string]any
+
+func loadOpenAPI(t *testing.T) openapiDocument {
+	t.Helper()
+	var spec openapiDocument
+	err := json.Unmarshal(openapiSpec, &spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return spec
+}
+
+// resolve follows $ref pointers until it reaches an inline object.
+func (spec openapiDocument) resolve(node any) map[string]any {
+	object, _ := node.(map[string]any)
+	for object != nil {
+		ref, ok := object["$ref"].(string)
+		if !ok {
+			return object
+		}
+		var target any = map[string]any(spec)
+		for _, key := range strings.Split(strings.TrimPrefix(ref, "#/"), "/") {
+			target = target.(map[string]any)[key]
+		}
+		object, _ = target.(map[string]any)
+	}
+	return nil
+}
+
+// validate checks value against the subset of JSON Schema used by
+// openapi.json and returns one problem per mismatch.
+func (spec openapiDocument) validate(schema map[string]any, value any, at string) []string {
+	schema = spec.resolve(schema)
+	if schema == nil {
+		return nil
+	}
+	if branches, ok := schema["oneOf"].([]any); ok {
+		matched := 0
+		for _, branch := range branches {
+			if len(spec.validate(branch.(map[string]any), value, at)) == 0 {
+				matched++
+			}
+		}
+		if matched != 1 {
+			return []string{fmt.Sprintf("%s: %v matches %d oneOf branches, want 1", at, value, matched)}
+		}
+	}
+	if branches, ok := schema["anyOf"].([]any); ok && !slices.ContainsFunc(branches, func(branch any) bool {
+		return len(spec.validate(branch.(map[string]any), value, at)) == 0
+	}) {
+		return []string{fmt.Sprintf("%s: %v matches no anyOf branch", at, value)}
+	}
+	if types := schemaTypes(schema["type"]); len(types) > 0 && !slices.ContainsFunc(types, func(typ string) bool { return jsonTypeIs(value, typ) }) {
+		return []string{fmt.Sprintf("%s: %v is not of type %v", at, value, types)}
+	}
+	var problems []string
+	if enum, ok := schema["enum"].([]any); ok && !slices.ContainsFunc(enum, func(allowed any) bool { return fmt.Sprint(allowed) == fmt.Sprint(value) }) {
+		problems = append(problems, fmt.Sprintf("%s: %v is not one of %v", at, value, enum))
+	}
+	switch value := value.(type) {
+	case string:
+		if pattern, ok := schema["pattern"].(string); ok && !regexp.MustCompile(pattern).MatchString(value) {
+			problems = append(problems, fmt.Sprintf("%s: %q does not match %s", at, value, pattern))
+		}
+		switch schema["format"] {
+		case "date":
+			if _, err := time.Parse(time.DateOnly, value); err != nil {
+				problems = append(problems, fmt.Sprintf("%s: %q is not a date", at, value))
+			}
+		case "date-time":
+			if _, err := time.Parse(time.RFC3339, value); err != nil {
+				problems = append(problems, fmt.Sprintf("%s: %q is not a date-time", at, value))
+			}
+		}
+	case []any:
+		if items, ok := schema["items"].(map[string]any); ok {
+			for i, item := range value {
+				problems = append(problems, spec.validate(items, item, fmt.Sprintf("%s[%d]", at, i))...)
+			}
+		}
+	case map[string]any:
+		properties, _ := schema["properties"].(map[string]any)
+		required, _ := schema["required"].([]any)
+		for _, name := range required {
+			if _, ok := value[name.(string)]; !ok {
+				problems = append(problems, fmt.Sprintf("%s: missing required member %q", at, name))
+			}
+		}
+		for name, member := range value {
+			if property, ok := properties[name].(map[string]any); ok {
+				problems = append(problems, spec.validate(property, member, at+"."+name)...)
+				continue
+			}
+			switch additional := schema["additionalProperties"].(type) {
+			case bool:
+				if !additional {
+					problems = append(problems, fmt.Sprintf("%s: unexpected member %q", at, name))
+				}
+			case map[string]any:
+				problems = append(problems, spec.validate(additional, member, at+"."+name)...)
+			}
+		}
+	}
+	return problems
+}
+
+func schemaTypes(typ any) []string {
+	switch typ := typ.(type) {
+	case string:
+		return []string{typ}
+	case []any:
+		types := make([]string, len(typ))
+		for i, t := range typ {
+			types[i] = t.(string)
+		}
+		return types
+	}
+	return nil
+}
+
+func jsonTypeIs(value any, typ string) bool {
+	switch typ {
+	case "null":
+		return value == nil
+	case "boolean":
+		_, ok := value.(bool)
+		return ok
+	case "string":
+		_, ok := value.(string)
+		return ok
+	case "number":
+		_, ok := value.(json.Number)
+		return ok
+	case "integer":
+		n, ok := value.(json.Number)
+		if !ok {
+			return false
+		}
+		_, err := n.Int64()
+		return err == nil
+	case "array":
+		_, ok := value.([]any)
+		return ok
+	case "object":
+		_, ok := value.(map[string]any)
+		return ok
+	}
+	return false
+}
+
+func decodeJSONValue(t *testing.T, text []byte) any {
+	t.Helper()
+	decoder := json.NewDecoder(bytes.NewReader(text))
+	decoder.UseNumber()
+	var value any
+	err := decoder.Decode(&value)
+	if err != nil {
+		t.Fatalf("invalid JSON %q: %v", text, err)
+	}
+	return value
+}
+
+func (spec openapiDocument) operation(t *testing.T, pattern string) map[string]any {
+	t.Helper()
+	method, path, _ := strings.Cut(pattern, " ")
+	paths := spec["paths"].(map[string]any)
+	item, _ := paths[path].(map[string]any)
+	op, ok := item[strings.ToLower(method)].(map[string]any)
+	if !ok {
+		t.Fatalf("%s is not in openapi.json", pattern)
+	}
+	return op
+}
+
+// checkRequest validates a JSON request body against the operation's schema.
+func (spec openapiDocument) checkRequest(t *testing.T, pattern, body string) {
+	t.Helper()
+	requestBody := spec.resolve(spec.operation(t, pattern)["requestBody"])
+	if requestBody == nil {
+		t.Fatalf("%s documents no request body", pattern)
+	}
+	media, ok := requestBody["content"].(map[string]any)["application/json"].(map[string]any)
+	if !ok {
+		t.Fatalf("%s documents no application/json request body", pattern)
+	}
+	for _, problem := range spec.validate(media["schema"].(map[string]any), decodeJSONValue(t, []byte(body)), "request") {
+		t.Errorf("%s: %s", pattern, problem)
+	}
+}
+
+// checkResponse validates a recorded response against the status, media
+// type, headers and schema documented for the operation.
+func (spec openapiDocument) checkResponse(t *testing.T, pattern string, status int, header http.Header, body []byte) {
+	t.Helper()
+	responses := spec.operation(t, pattern)["responses"].(map[string]any)
+	response := spec.resolve(responses[strconv.Itoa(status)])
+	if response == nil {
+		t.Fatalf("%s: status %d is not documented", pattern, status)
+	}
+	content, _ := response["content"].(map[string]any)
+	mediaType, _, err := mime.ParseMediaType(header.Get("Content-Type"))
+	if err != nil {
+		t.Fatalf("%s: Content-Type %q: %v", pattern, header.Get("Content-Type"), err)
+	}
+	media, ok := content[mediaType].(map[string]any)
+	if !ok {
+		t.Fatalf("%s: %d %s is not documented", pattern, status, mediaType)
+	}
+	documented, _ := response["headers"].(map[string]any)
+	var envelopeSchema map[string]any
+	if json, ok := content["application/json"].(map[string]any); ok {
+		envelopeSchema = spec.resolve(json["schema"])
+	}
+	for name, values := range header {
+		if name != "Location" && !strings.HasPrefix(name, "Envelope-") {
+			continue
+		}
+		if _, ok := documented[name]; !ok {
+			t.Errorf("%s: %d header %s is not documented", pattern, status, name)
+			continue
+		}
+		if member, ok := strings.CutPrefix(name, "Envelope-"); ok {
+			properties, _ := envelopeSchema["properties"].(map[string]any)
+			property, _ := properties[strings.ToLower(member)].(map[string]any)
+			for _, problem := range spec.validate(property, decodeJSONValue(t, []byte(values[0])), name) {
+				t.Errorf("%s: %s", pattern, problem)
+			}
+		}
+	}
+	if mediaType != "application/json" {
+		return
+	}
+	for _, problem := range spec.validate(media["schema"].(map[string]any), decodeJSONValue(t, body), "response") {
+		t.Errorf("%s: %s", pattern, problem)
+	}
+}
+
+func TestOpenAPIDescribesResponses(t *testing.T) {
+	spec := loadOpenAPI(t)
+	movie := &data.Movie{
+		ID:          1,
+		Type:        "movie",
+		Title:       "Moana",
+		Year:        2016,
+		Runtime:     107,
+		Genres:      []string{"animation", "adventure"},
+		Version:     1,
+		ExternalIDs: map[string]string{"imdb": "tt3521164"},
+	}
+	tests := []struct {
+		name    string
+		method  string
+		target  string
+		pattern string
+		body    string
+		header  http.Header
+		status  int
+	}{
+		{"healthcheck", http.MethodGet, "/v1/healthcheck", "GET /v1/healthcheck", "", nil, http.StatusOK},
+		{"show", http.MethodGet, "/v1/movies/1", "GET /v1/movies/{id}", "", nil, http.StatusOK},
+		{"show minutes", http.MethodGet, "/v1/movies/1?runtime_format=minutes", "GET /v1/movies/{id}", "", nil, http.StatusOK},
+		{"show iso8601", http.MethodGet, "/v1/movies/1", "GET /v1/movies/{id}", "", http.Header{"Runtime-Format": {"iso8601"}}, http.StatusOK},
+		{"show human", http.MethodGet, "/v1/movies/1?runtime_format=human", "GET /v1/movies/{id}", "", nil, http.StatusOK},
+		{"show missing", http.MethodGet, "/v1/movies/2", "GET /v1/movies/{id}", "", nil, http.StatusNotFound},
+		{"list", http.MethodGet, "/v1/movies", "GET /v1/movies", "", nil, http.StatusOK},
+		{"list csv", http.MethodGet, "/v1/movies", "GET /v1/movies", "", http.Header{"Accept": {"text/csv"}}, http.StatusOK},
+		{"list invalid", http.MethodGet, "/v1/movies?page_size=0", "GET /v1/movies", "", nil, http.StatusUnprocessableEntity},
+		{"create", http.MethodPost, "/v1/movies", "POST /v1/movies", `{"title":"Up","year":2009,"runtime":"1h 36m","genres":["animation"],"releases":[{"country":"US","date":"2009-05-29","type":"theatrical"}]}`, nil, http.StatusCreated},
+		{"create invalid", http.MethodPost, "/v1/movies", "POST /v1/movies", `{"title":"","genres":["animation"]}`, nil, http.StatusUnprocessableEntity},
+		{"graphql", http.MethodPost, "/v1/graphql", "POST /v1/graphql", `{"query":"{ movie(id: \"1\") { title runtime } me { id } }"}`, nil, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newGraphQLTestApplication(t)
+			app.models.Movies = storedMovie{movie: movie}
+
+			if tt.body != "" {
+				spec.checkRequest(t, tt.pattern, tt.body)
+			}
+			rr := serve(t, app.routes(), tt.method, tt.target, tt.body, tt.header)
+			if rr.Code != tt.status {
+				t.Fatalf("status = %d, want %d; body %s", rr.Code, tt.status, rr.Body)
+			}
+			spec.checkResponse(t, tt.pattern, rr.Code, rr.Header(), rr.Body.Bytes())
+		})
+	}
+}
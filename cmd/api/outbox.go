@@ -0,0 +1,187 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/noonacedia/cinematrique/internal/data"
+	"github.com/noonacedia/cinematrique/internal/jsonlog"
+)
+
+var outboxSinkNames = []string{"webhooks", "log", "sse"}
+
+type eventSink interface {
+	Publish(event *data.OutboxEvent) error
+}
+
+type webhookSink struct {
+	models data.Models
+}
+
+func (s webhookSink) Publish(event *data.OutboxEvent) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	return s.models.WebhookDeliveries.Enqueue(event.Event, payload)
+}
+
+type logSink struct {
+	logger *jsonlog.Logger
+}
+
+func (s logSink) Publish(event *data.OutboxEvent) error {
+	s.logger.PrintInfo("event published", map[string]string{
+		"id":    strconv.FormatInt(event.ID, 10),
+		"event": event.Event,
+	})
+	return nil
+}
+
+type eventHub struct {
+	mu          sync.Mutex
+	closed      bool
+	subscribers map[chan *data.OutboxEvent]struct{}
+}
+
+func newEventHub() *eventHub {
+	return &eventHub{subscribers: make(map[chan *data.OutboxEvent]struct{})}
+}
+
+func (h *eventHub) Subscribe(buffer int) chan *data.OutboxEvent {
+	ch := make(chan *data.OutboxEvent, buffer)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		close(ch)
+		return ch
+	}
+	h.subscribers[ch] = struct{}{}
+	return ch
+}
+
+func (h *eventHub) Unsubscribe(ch chan *data.OutboxEvent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := h.subscribers[ch]; ok {
+		delete(h.subscribers, ch)
+		close(ch)
+	}
+}
+
+func (h *eventHub) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.closed = true
+	for ch := range h.subscribers {
+		delete(h.subscribers, ch)
+		close(ch)
+	}
+}
+
+func (h *eventHub) Publish(event *data.OutboxEvent) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for ch := range h.subscribers {
+		select {
+		case ch <- event:
+		default:
+			delete(h.subscribers, ch)
+			close(ch)
+		}
+	}
+	return nil
+}
+
+func (app *application) outboxSinks() ([]eventSink, error) {
+	sinks := make([]eventSink, 0, len(app.config.outbox.sinks))
+	for _, name := range app.config.outbox.sinks {
+		switch name {
+		case "webhooks":
+			sinks = append(sinks, webhookSink{models: app.models})
+		case "log":
+			sinks = append(sinks, logSink{logger: app.logger})
+		case "sse":
+			sinks = append(sinks, outboxWakeSink{app: app})
+		default:
+			return nil, fmt.Errorf("unknown outbox sink %q, must be one of: %s", name, strings.Join(outboxSinkNames, ", "))
+		}
+	}
+	return sinks, nil
+}
+
+func (app *application) relayOutbox(sinks []eventSink) error {
+	for {
+		events, err := app.models.Outbox.GetUnpublished(app.config.outbox.batchSize)
+		if err != nil {
+			return err
+		}
+		published := make([]int64, 0, len(events))
+		for _, event := range events {
+			for _, sink := range sinks {
+				err = sink.Publish(event)
+				if err != nil {
+					break
+				}
+			}
+			if err != nil {
+				break
+			}
+			published = append(published, event.ID)
+		}
+		if len(published) > 0 {
+			markErr := app.models.Outbox.MarkPublished(published)
+			if markErr != nil {
+				return markErr
+			}
+		}
+		if err != nil || len(events) < app.config.outbox.batchSize {
+			return err
+		}
+	}
+}
+
+func (app *application) runOutboxRelay(sinks []eventSink) {
+	app.runRecoverableBackground(func() {
+		var lease *data.OutboxLease
+		defer func() {
+			if lease != nil {
+				lease.Release()
+			}
+		}()
+		ticker := time.NewTicker(app.config.outbox.pollInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-app.done:
+				return
+			case <-ticker.C:
+			}
+			if lease != nil && !lease.Alive() {
+				app.logger.PrintInfo("lost outbox relay leadership", nil)
+				lease.Release()
+				lease = nil
+			}
+			if lease == nil {
+				var err error
+				lease, err = app.models.Outbox.TryLead()
+				if err != nil {
+					app.logger.PrintError(err, nil)
+					continue
+				}
+				if lease == nil {
+					continue
+				}
+				app.logger.PrintInfo("acquired outbox relay leadership", nil)
+			}
+			err := app.relayOutbox(sinks)
+			if err != nil {
+				app.logger.PrintError(err, nil)
+			}
+		}
+	})
+}
@@ -1,19 +1,70 @@
 package main
 
-import "net/http"
+import (
+	"net/http"
+)
 
 func (app *application) routes() http.Handler {
 	mux := http.NewServeMux()
 
 	mux.HandleFunc("GET /v1/healthcheck", app.healthcheckHandler)
+	mux.HandleFunc("GET /v1/openapi.json", app.openapiHandler)
+	mux.HandleFunc("GET /v1/docs", app.docsHandler)
 
 	mux.HandleFunc("POST /v1/movies", app.createMovieHandler)
 	mux.HandleFunc("GET /v1/movies", app.listMoviesHandler)
+	mux.HandleFunc("GET /v1/movies/lookup", app.lookupMovieHandler)
 	mux.HandleFunc("GET /v1/movies/{id}", app.showMovieHandler)
 	mux.HandleFunc("PATCH /v1/movies/{id}", app.updateMovieHandler)
 	mux.HandleFunc("DELETE /v1/movies/{id}", app.removeMovieHandler)
 
+	mux.HandleFunc("POST /v1/movies/{id}/merge", app.mergeMovieHandler)
+	mux.HandleFunc("GET /v1/movies/{id}/similar", app.listSimilarMoviesHandler)
+
+	mux.HandleFunc("GET /v1/movies/{id}/titles", app.listMovieTitlesHandler)
+	mux.HandleFunc("POST /v1/movies/{id}/titles", app.createMovieTitleHandler)
+	mux.HandleFunc("DELETE /v1/movies/{id}/titles/{title_id}", app.removeMovieTitleHandler)
+
+	mux.HandleFunc("GET /v1/movies/{id}/releases", app.listReleasesHandler)
+	mux.HandleFunc("POST /v1/movies/{id}/releases", app.createReleaseHandler)
+	mux.HandleFunc("DELETE /v1/movies/{id}/releases/{release_id}", app.removeReleaseHandler)
+
+	mux.HandleFunc("POST /v1/movies/{id}/images", app.uploadMovieImageHandler)
+	mux.HandleFunc("GET /v1/images/{key...}", app.serveImageHandler)
+
+	mux.HandleFunc("POST /v1/collections", app.createCollectionHandler)
+	mux.HandleFunc("GET /v1/collections", app.listCollectionsHandler)
+	mux.HandleFunc("GET /v1/collections/{id}", app.showCollectionHandler)
+	mux.HandleFunc("GET /v1/collections/{id}/movies", app.listCollectionMoviesHandler)
+	mux.HandleFunc("PATCH /v1/collections/{id}", app.updateCollectionHandler)
+	mux.HandleFunc("DELETE /v1/collections/{id}", app.removeCollectionHandler)
+
+	mux.HandleFunc("GET /v1/series/{id}", app.showSeriesHandler)
+	mux.HandleFunc("GET /v1/series/{id}/seasons", app.listSeasonsHandler)
+	mux.HandleFunc("POST /v1/series/{id}/seasons", app.createSeasonHandler)
+	mux.HandleFunc("GET /v1/series/{id}/seasons/{season}", app.showSeasonHandler)
+	mux.HandleFunc("POST /v1/series/{id}/seasons/{season}/episodes", app.createEpisodeHandler)
+	mux.HandleFunc("GET /v1/series/{id}/seasons/{season}/episodes/{episode}", app.showEpisodeHandler)
+
+	mux.HandleFunc("POST /v1/batch", app.batchHandler)
+
+	mux.HandleFunc("POST /v1/graphql", app.graphqlHandler)
+
+	mux.HandleFunc("GET /v1/events", app.streamEventsHandler)
+
+	mux.HandleFunc("POST /v1/webhooks", app.createWebhookHandler)
+	mux.HandleFunc("GET /v1/webhooks", app.listWebhooksHandler)
+	mux.HandleFunc("GET /v1/webhooks/{id}", app.showWebhookHandler)
+	mux.HandleFunc("PATCH /v1/webhooks/{id}", app.updateWebhookHandler)
+	mux.HandleFunc("DELETE /v1/webhooks/{id}", app.removeWebhookHandler)
+	mux.HandleFunc("GET /v1/webhooks/{id}/deliveries", app.listWebhookDeliveriesHandler)
+	mux.HandleFunc("POST /v1/webhooks/{id}/deliveries/{delivery_id}/redeliver", app.redeliverWebhookHandler)
+
 	mux.HandleFunc("POST /v1/users", app.registerUser)
 
-	return app.recoverPanic(app.rateLimit(mux))
+	root := http.NewServeMux()
+	root.Handle("GET /v1/movies/suggest", app.rateLimitWith(app.config.suggest.limiter, http.HandlerFunc(app.suggestMoviesHandler)))
+	root.Handle("/", app.rateLimit(mux))
+
+	return app.compress(app.recoverPanic(app.negotiate(app.idempotent(app.runtimeFormat(root)))))
 }
@@ -0,0 +1,272 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/noonacedia/cinematrique/internal/data"
+	"github.com/noonacedia/cinematrique/internal/validator"
+)
+
+func (app *application) getSeries(r *http.Request) (*data.Movie, error) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		return nil, data.ErrRecordNotFound
+	}
+	series, err := app.models.Movies.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if series.Type != "series" {
+		return nil, data.ErrRecordNotFound
+	}
+	return series, nil
+}
+
+func readNumberPathParam(r *http.Request, name string) (int32, error) {
+	number, err := strconv.ParseInt(r.PathValue(name), 10, 32)
+	if err != nil || number < 0 {
+		return 0, data.ErrRecordNotFound
+	}
+	return int32(number), nil
+}
+
+func (app *application) showSeriesHandler(w http.ResponseWriter, r *http.Request) {
+	series, err := app.getSeries(r)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	seasons, err := app.models.Series.GetSeasons(series.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	err = app.localizeMovies(w, r, series)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	err = app.writeJSON(w, http.StatusOK, envelope{"series": series, "seasons": seasons}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) listSeasonsHandler(w http.ResponseWriter, r *http.Request) {
+	series, err := app.getSeries(r)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	seasons, err := app.models.Series.GetSeasons(series.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	err = app.writeJSON(w, http.StatusOK, envelope{"seasons": seasons}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) createSeasonHandler(w http.ResponseWriter, r *http.Request) {
+	series, err := app.getSeries(r)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	var input struct {
+		Number  int32      `json:"number"`
+		Name    string     `json:"name"`
+		AirDate *data.Date `json:"air_date"`
+	}
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	season := &data.Season{
+		SeriesID: series.ID,
+		Number:   input.Number,
+		Name:     input.Name,
+		AirDate:  input.AirDate,
+	}
+	v := validator.New()
+	if data.ValidateSeason(v, season); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+	err = app.models.Series.InsertSeason(season)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrDuplicateSeason):
+			v.AddError("number", "a season with this number already exists")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	headers := make(http.Header)
+	headers.Set("Location", fmt.Sprintf("v1/series/%d/seasons/%d", series.ID, season.Number))
+	err = app.writeJSON(w, http.StatusCreated, envelope{"season": season}, headers)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) showSeasonHandler(w http.ResponseWriter, r *http.Request) {
+	series, err := app.getSeries(r)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	number, err := readNumberPathParam(r, "season")
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+	season, err := app.models.Series.GetSeason(series.ID, number)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	err = app.writeJSON(w, http.StatusOK, envelope{"season": season}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) createEpisodeHandler(w http.ResponseWriter, r *http.Request) {
+	series, err := app.getSeries(r)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	number, err := readNumberPathParam(r, "season")
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+	season, err := app.models.Series.GetSeason(series.ID, number)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	var input struct {
+		Number  int32        `json:"number"`
+		Title   string       `json:"title"`
+		Runtime data.Runtime `json:"runtime"`
+		AirDate *data.Date   `json:"air_date"`
+	}
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	episode := &data.Episode{
+		SeasonID:     season.ID,
+		SeasonNumber: season.Number,
+		Number:       input.Number,
+		Title:        input.Title,
+		Runtime:      input.Runtime,
+		AirDate:      input.AirDate,
+	}
+	v := validator.New()
+	if data.ValidateEpisode(v, episode); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+	err = app.models.Series.InsertEpisode(episode)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrDuplicateEpisode):
+			v.AddError("number", "an episode with this number already exists in the season")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	headers := make(http.Header)
+	headers.Set("Location", fmt.Sprintf("v1/series/%d/seasons/%d/episodes/%d", series.ID, season.Number, episode.Number))
+	err = app.writeJSON(w, http.StatusCreated, envelope{"episode": episode}, headers)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) showEpisodeHandler(w http.ResponseWriter, r *http.Request) {
+	series, err := app.getSeries(r)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	seasonNumber, err := readNumberPathParam(r, "season")
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+	number, err := readNumberPathParam(r, "episode")
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+	episode, err := app.models.Series.GetEpisode(series.ID, seasonNumber, number)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	err = app.writeJSON(w, http.StatusOK, envelope{"episode": episode}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
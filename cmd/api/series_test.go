@@ -0,0 +1,182 @@
+package main
+
+import (
+	"encoding/json"
+	"maps"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/noonacedia/cinematrique/internal/data"
+)
+
+// catalogMovies serves a fixed set of movies by id.
+type catalogMovies struct {
+	data.MockMovieModel
+	movies map[int64]*data.Movie
+}
+
+func (m catalogMovies) Get(id int64) (*data.Movie, error) {
+	movie, ok := m.movies[id]
+	if !ok {
+		return nil, data.ErrRecordNotFound
+	}
+	return movie, nil
+}
+
+// memorySeries keeps seasons and episodes in memory and enforces the same
+// unique numbers as the seasons and episodes tables.
+type memorySeries struct {
+	data.MockSeriesModel
+	seasons map[int32]*data.Season
+}
+
+func (m *memorySeries) InsertSeason(season *data.Season) error {
+	if _, ok := m.seasons[season.Number]; ok {
+		return data.ErrDuplicateSeason
+	}
+	season.ID = int64(len(m.seasons) + 1)
+	m.seasons[season.Number] = season
+	return nil
+}
+
+func (m *memorySeries) GetSeasons(seriesID int64) ([]*data.Season, error) {
+	return []*data.Season{}, nil
+}
+
+func (m *memorySeries) GetSeason(seriesID int64, number int32) (*data.Season, error) {
+	season, ok := m.seasons[number]
+	if !ok || season.SeriesID != seriesID {
+		return nil, data.ErrRecordNotFound
+	}
+	return season, nil
+}
+
+func (m *memorySeries) InsertEpisode(episode *data.Episode) error {
+	season := m.seasons[episode.SeasonNumber]
+	for _, existing := range season.Episodes {
+		if existing.Number == episode.Number {
+			return data.ErrDuplicateEpisode
+		}
+	}
+	episode.ID = int64(len(season.Episodes) + 1)
+	season.Episodes = append(season.Episodes, episode)
+	return nil
+}
+
+func (m *memorySeries) GetEpisode(seriesID int64, seasonNumber, number int32) (*data.Episode, error) {
+	season, err := m.GetSeason(seriesID, seasonNumber)
+	if err != nil {
+		return nil, err
+	}
+	for _, episode := range season.Episodes {
+		if episode.Number == number {
+			return episode, nil
+		}
+	}
+	return nil, data.ErrRecordNotFound
+}
+
+func newSeriesTestApplication(t *testing.T) *application {
+	t.Helper()
+	app := newTestApplication(t)
+	app.models.Movies = catalogMovies{movies: map[int64]*data.Movie{
+		1: {ID: 1, Type: "series", Title: "The Wire", Year: 2002, Genres: []string{"drama"}},
+		2: {ID: 2, Type: "movie", Title: "Heat", Year: 1995, Runtime: 170, Genres: []string{"crime"}},
+	}}
+	app.models.Series = &memorySeries{seasons: map[int32]*data.Season{
+		1: {ID: 1, SeriesID: 1, Number: 1, Episodes: []*data.Episode{{ID: 1, SeasonID: 1, SeasonNumber: 1, Number: 1, Title: "The Target"}}},
+	}}
+	return app
+}
+
+func TestSeasonRoutes(t *testing.T) {
+	tests := []struct {
+		name       string
+		method     string
+		target     string
+		body       string
+		wantStatus int
+		wantError  map[string]string
+	}{
+		{"create", http.MethodPost, "/v1/series/1/seasons", `{"number":2,"name":"Two","air_date":"2003-06-01"}`, http.StatusCreated, nil},
+		{"specials", http.MethodPost, "/v1/series/1/seasons", `{"number":0}`, http.StatusCreated, nil},
+		{"negative number", http.MethodPost, "/v1/series/1/seasons", `{"number":-1}`, http.StatusUnprocessableEntity, map[string]string{"number": "must not be negative"}},
+		{"large number", http.MethodPost, "/v1/series/1/seasons", `{"number":1001}`, http.StatusUnprocessableEntity, map[string]string{"number": "must not be more than 1000"}},
+		{"long name", http.MethodPost, "/v1/series/1/seasons", `{"number":2,"name":"` + strings.Repeat("a", 501) + `"}`, http.StatusUnprocessableEntity, map[string]string{"name": "must not be more than 500 bytes long"}},
+		{"duplicate number", http.MethodPost, "/v1/series/1/seasons", `{"number":1}`, http.StatusUnprocessableEntity, map[string]string{"number": "a season with this number already exists"}},
+		{"bad air date", http.MethodPost, "/v1/series/1/seasons", `{"number":2,"air_date":"2003-13-01"}`, http.StatusBadRequest, nil},
+		{"create on a movie", http.MethodPost, "/v1/series/2/seasons", `{"number":1}`, http.StatusNotFound, nil},
+		{"create on unknown series", http.MethodPost, "/v1/series/9/seasons", `{"number":1}`, http.StatusNotFound, nil},
+		{"create on malformed id", http.MethodPost, "/v1/series/abc/seasons", `{"number":1}`, http.StatusNotFound, nil},
+		{"list", http.MethodGet, "/v1/series/1/seasons", "", http.StatusOK, nil},
+		{"list on a movie", http.MethodGet, "/v1/series/2/seasons", "", http.StatusNotFound, nil},
+		{"show", http.MethodGet, "/v1/series/1/seasons/1", "", http.StatusOK, nil},
+		{"show unknown season", http.MethodGet, "/v1/series/1/seasons/5", "", http.StatusNotFound, nil},
+		{"show negative season", http.MethodGet, "/v1/series/1/seasons/-1", "", http.StatusNotFound, nil},
+		{"show on a movie", http.MethodGet, "/v1/series/2/seasons/1", "", http.StatusNotFound, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newSeriesTestApplication(t)
+			rr := serve(t, app.routes(), tt.method, tt.target, tt.body, nil)
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", rr.Code, tt.wantStatus, rr.Body)
+			}
+			checkErrorBody(t, rr.Body.Bytes(), tt.wantError)
+		})
+	}
+}
+
+func TestEpisodeRoutes(t *testing.T) {
+	tests := []struct {
+		name       string
+		method     string
+		target     string
+		body       string
+		wantStatus int
+		wantError  map[string]string
+	}{
+		{"create", http.MethodPost, "/v1/series/1/seasons/1/episodes", `{"number":2,"title":"The Detail","runtime":"58 mins"}`, http.StatusCreated, nil},
+		{"zero number", http.MethodPost, "/v1/series/1/seasons/1/episodes", `{"number":0,"title":"The Detail"}`, http.StatusUnprocessableEntity, map[string]string{"number": "must be positive"}},
+		{"missing title", http.MethodPost, "/v1/series/1/seasons/1/episodes", `{"number":2}`, http.StatusUnprocessableEntity, map[string]string{"title": "must be provided"}},
+		{"long title", http.MethodPost, "/v1/series/1/seasons/1/episodes", `{"number":2,"title":"` + strings.Repeat("a", 501) + `"}`, http.StatusUnprocessableEntity, map[string]string{"title": "must not be more than 500 bytes long"}},
+		{"bad runtime", http.MethodPost, "/v1/series/1/seasons/1/episodes", `{"number":2,"title":"The Detail","runtime":"long"}`, http.StatusBadRequest, nil},
+		{"duplicate number", http.MethodPost, "/v1/series/1/seasons/1/episodes", `{"number":1,"title":"The Target"}`, http.StatusUnprocessableEntity, map[string]string{"number": "an episode with this number already exists in the season"}},
+		{"create in unknown season", http.MethodPost, "/v1/series/1/seasons/5/episodes", `{"number":1,"title":"Pilot"}`, http.StatusNotFound, nil},
+		{"create on a movie", http.MethodPost, "/v1/series/2/seasons/1/episodes", `{"number":1,"title":"Pilot"}`, http.StatusNotFound, nil},
+		{"create on unknown series", http.MethodPost, "/v1/series/9/seasons/1/episodes", `{"number":1,"title":"Pilot"}`, http.StatusNotFound, nil},
+		{"show", http.MethodGet, "/v1/series/1/seasons/1/episodes/1", "", http.StatusOK, nil},
+		{"show unknown episode", http.MethodGet, "/v1/series/1/seasons/1/episodes/7", "", http.StatusNotFound, nil},
+		{"show in unknown season", http.MethodGet, "/v1/series/1/seasons/5/episodes/1", "", http.StatusNotFound, nil},
+		{"show malformed episode", http.MethodGet, "/v1/series/1/seasons/1/episodes/one", "", http.StatusNotFound, nil},
+		{"show on a movie", http.MethodGet, "/v1/series/2/seasons/1/episodes/1", "", http.StatusNotFound, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newSeriesTestApplication(t)
+			rr := serve(t, app.routes(), tt.method, tt.target, tt.body, nil)
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", rr.Code, tt.wantStatus, rr.Body)
+			}
+			checkErrorBody(t, rr.Body.Bytes(), tt.wantError)
+		})
+	}
+}
+
+func checkErrorBody(t *testing.T, body []byte, want map[string]string) {
+	t.Helper()
+	if want == nil {
+		return
+	}
+	var res struct {
+		Error map[string]string `json:"error"`
+	}
+	err := json.Unmarshal(body, &res)
+	if err != nil {
+		t.Fatalf("%v: %s", err, body)
+	}
+	if !maps.Equal(res.Error, want) {
+		t.Errorf("errors = %v, want %v", res.Error, want)
+	}
+}
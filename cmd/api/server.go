@@ -5,11 +5,14 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
 	"time"
+
+	"google.golang.org/grpc"
 )
 
 func (app *application) serve() error {
@@ -21,6 +24,42 @@ func (app *application) serve() error {
 		IdleTimeout:  1 * time.Minute,
 		ErrorLog:     log.New(app.logger, "", 0),
 	}
+	server.RegisterOnShutdown(app.events.Close)
+	var grpcServer *grpc.Server
+	if app.config.grpc.port != 0 {
+		listener, err := net.Listen("tcp", fmt.Sprintf(":%d", app.config.grpc.port))
+		if err != nil {
+			return err
+		}
+		grpcServer = app.grpcServer()
+		app.logger.PrintInfo("starting grpc server", map[string]string{"addr": listener.Addr().String()})
+		go func() {
+			err := grpcServer.Serve(listener)
+			if err != nil {
+				app.logger.PrintError(err, map[string]string{"addr": listener.Addr().String()})
+			}
+		}()
+	}
+	var metricsServer *http.Server
+	if app.config.metrics.addr != "" {
+		listener, err := net.Listen("tcp", app.config.metrics.addr)
+		if err != nil {
+			return err
+		}
+		metricsServer = &http.Server{
+			Handler:      app.metricsRoutes(),
+			ReadTimeout:  1 * time.Second,
+			WriteTimeout: 2 * time.Second,
+			ErrorLog:     log.New(app.logger, "", 0),
+		}
+		app.logger.PrintInfo("starting metrics server", map[string]string{"addr": listener.Addr().String()})
+		go func() {
+			err := metricsServer.Serve(listener)
+			if !errors.Is(err, http.ErrServerClosed) {
+				app.logger.PrintError(err, map[string]string{"addr": listener.Addr().String()})
+			}
+		}()
+	}
 	shutdownError := make(chan error)
 	go func() {
 		quit := make(chan os.Signal, 1)
@@ -29,11 +68,33 @@ func (app *application) serve() error {
 		app.logger.PrintInfo("caught signal", map[string]string{"signal": s.String()})
 		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 		defer cancel()
+		grpcStopped := make(chan struct{})
+		go func() {
+			defer close(grpcStopped)
+			if grpcServer == nil {
+				return
+			}
+			stopped := make(chan struct{})
+			go func() {
+				grpcServer.GracefulStop()
+				close(stopped)
+			}()
+			select {
+			case <-stopped:
+			case <-ctx.Done():
+				grpcServer.Stop()
+			}
+		}()
+		if metricsServer != nil {
+			metricsServer.Shutdown(ctx)
+		}
 		err := server.Shutdown(ctx)
+		<-grpcStopped
 		if err != nil {
 			shutdownError <- err
 		}
 		app.logger.PrintInfo("completing background tasks", map[string]string{"addr": server.Addr})
+		close(app.done)
 		app.wg.Wait()
 		shutdownError <- nil
 	}()
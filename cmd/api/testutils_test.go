@@ -0,0 +1,111 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/noonacedia/cinematrique/internal/data"
+	"github.com/noonacedia/cinematrique/internal/jsonlog"
+)
+
+func newTestApplication(t *testing.T) *application {
+	t.Helper()
+	app := &application{
+		logger: jsonlog.New(io.Discard, jsonlog.LevelOff),
+		models: data.NewMockModels(),
+		done:   make(chan struct{}),
+	}
+	app.config.env = "testing"
+	app.config.compress.enabled = true
+	app.config.compress.minSize = 1_024
+	app.config.idempotency.ttl = 24 * time.Hour
+	app.config.idempotency.lockTimeout = time.Minute
+	return app
+}
+
+func serve(t *testing.T, h http.Handler, method, target, body string, header http.Header) *httptest.ResponseRecorder {
+	t.Helper()
+	var rd io.Reader
+	if body != "" {
+		rd = strings.NewReader(body)
+	}
+	r := httptest.NewRequest(method, target, rd)
+	for name, values := range header {
+		r.Header[name] = values
+	}
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, r)
+	return rr
+}
+
+// storedMovie serves a single movie from every read path.
+type storedMovie struct {
+	data.MockMovieModel
+	movie *data.Movie
+}
+
+func (m storedMovie) Get(id int64) (*data.Movie, error) {
+	if id != m.movie.ID {
+		return nil, data.ErrRecordNotFound
+	}
+	return m.movie, nil
+}
+
+func (m storedMovie) GetFields(id int64, fields []string) (*data.Movie, error) {
+	return m.Get(id)
+}
+
+func (m storedMovie) GetRedirect(id int64) (int64, error) {
+	return 0, data.ErrRecordNotFound
+}
+
+func (m storedMovie) GetAll(search data.MovieSearch, filters data.Filters) ([]*data.Movie, data.Metadata, error) {
+	return []*data.Movie{m.movie}, data.Metadata{}, nil
+}
+
+// memoryIdempotencyKeys is an in-memory stand-in for the idempotency_keys
+// table that follows the same claim, replay and release rules.
+type memoryIdempotencyKeys struct {
+	mu      sync.Mutex
+	records map[string]*data.IdempotencyRecord
+}
+
+func newMemoryIdempotencyKeys() *memoryIdempotencyKeys {
+	return &memoryIdempotencyKeys{records: make(map[string]*data.IdempotencyRecord)}
+}
+
+func (m *memoryIdempotencyKeys) Begin(key string, fingerprint []byte, ttl, lockTimeout time.Duration) (*data.IdempotencyRecord, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if record, ok := m.records[key]; ok {
+		copied := *record
+		return &copied, nil
+	}
+	m.records[key] = &data.IdempotencyRecord{Key: key, Fingerprint: fingerprint}
+	return nil, nil
+}
+
+func (m *memoryIdempotencyKeys) Complete(record *data.IdempotencyRecord) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.records[record.Key] = record
+	return nil
+}
+
+func (m *memoryIdempotencyKeys) Release(key string, fingerprint []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if record, ok := m.records[key]; ok && record.InFlight() && record.Matches(fingerprint) {
+		delete(m.records, key)
+	}
+	return nil
+}
+
+func (m *memoryIdempotencyKeys) DeleteExpired() error {
+	return nil
+}
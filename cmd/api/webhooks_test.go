@@ -0,0 +1,201 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/noonacedia/cinematrique/internal/data"
+)
+
+// verifySignature checks a Cinematrique-Signature header the way a receiver
+// is documented to.
+func verifySignature(secret, header string, body []byte) error {
+	var timestamp, signature string
+	for _, part := range strings.Split(header, ",") {
+		key, value, _ := strings.Cut(part, "=")
+		switch key {
+		case "t":
+			timestamp = value
+		case "v1":
+			signature = value
+		}
+	}
+	if _, err := strconv.ParseInt(timestamp, 10, 64); err != nil {
+		return fmt.Errorf("bad timestamp %q", timestamp)
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	io.WriteString(mac, timestamp+".")
+	mac.Write(body)
+	got, err := hex.DecodeString(signature)
+	if err != nil || !hmac.Equal(got, mac.Sum(nil)) {
+		return fmt.Errorf("signature %q does not match", signature)
+	}
+	return nil
+}
+
+func TestSendWebhookSignature(t *testing.T) {
+	received := make(chan error, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received <- verifySignature("s3cret", r.Header.Get("Cinematrique-Signature"), body)
+	}))
+	defer srv.Close()
+
+	app := newTestApplication(t)
+	app.webhookClient = srv.Client()
+	status, err := app.sendWebhook(&data.WebhookDelivery{
+		ID:      1,
+		Event:   "movie.created",
+		Payload: []byte(`{"id":1}`),
+		URL:     srv.URL,
+		Secret:  "s3cret",
+	})
+	if err != nil || status != http.StatusOK {
+		t.Fatalf("sendWebhook = %d, %v", status, err)
+	}
+	if err := <-received; err != nil {
+		t.Error(err)
+	}
+	if err := verifySignature("other", signWebhook("s3cret", 1, []byte("{}")), []byte("{}")); err == nil {
+		t.Error("a signature verified with the wrong secret")
+	}
+}
+
+func TestWebhookBackoff(t *testing.T) {
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{1, 30 * time.Second},
+		{2, time.Minute},
+		{3, 2 * time.Minute},
+		{10, 256 * time.Minute},
+		{11, 6 * time.Hour},
+		{1_000, 6 * time.Hour},
+	}
+	for _, tt := range tests {
+		if got := webhookBackoff(tt.attempts); got != tt.want {
+			t.Errorf("webhookBackoff(%d) = %s, want %s", tt.attempts, got, tt.want)
+		}
+	}
+	for attempts := 1; attempts < 50; attempts++ {
+		if webhookBackoff(attempts+1) < webhookBackoff(attempts) {
+			t.Fatalf("backoff decreased after attempt %d", attempts)
+		}
+	}
+}
+
+// retryingDeliveries hands out a single delivery whenever it is due.
+type retryingDeliveries struct {
+	data.MockWebhookDeliveryModel
+	delivery *data.WebhookDelivery
+	limits   []int
+}
+
+func (m *retryingDeliveries) Claim(limit int, lease time.Duration) ([]*data.WebhookDelivery, error) {
+	m.limits = append(m.limits, limit)
+	if m.delivery.Status != "pending" {
+		return nil, nil
+	}
+	claimed := *m.delivery
+	return []*data.WebhookDelivery{&claimed}, nil
+}
+
+func (m *retryingDeliveries) RecordAttempt(delivery *data.WebhookDelivery) error {
+	m.delivery = delivery
+	return nil
+}
+
+func TestDeliverWebhooksDeadLetters(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	app := newTestApplication(t)
+	app.webhookClient = srv.Client()
+	app.config.webhooks.batchSize = 1
+	app.config.webhooks.maxAttempts = 3
+	app.config.webhooks.timeout = time.Second
+	deliveries := &retryingDeliveries{delivery: &data.WebhookDelivery{ID: 1, Status: "pending", URL: srv.URL}}
+	app.models.WebhookDeliveries = deliveries
+
+	for attempt := 1; attempt <= 3; attempt++ {
+		if err := app.deliverWebhooks(); err != nil {
+			t.Fatal(err)
+		}
+		got := deliveries.delivery
+		if got.Attempts != attempt || got.ResponseStatus != http.StatusServiceUnavailable {
+			t.Fatalf("attempt %d recorded %d attempts with status %d", attempt, got.Attempts, got.ResponseStatus)
+		}
+		wantStatus := "pending"
+		if attempt == 3 {
+			wantStatus = "dead"
+		}
+		if got.Status != wantStatus {
+			t.Errorf("after attempt %d status = %q, want %q", attempt, got.Status, wantStatus)
+		}
+	}
+	if err := app.deliverWebhooks(); err != nil {
+		t.Fatal(err)
+	}
+	if deliveries.delivery.Attempts != 3 {
+		t.Errorf("a dead delivery was attempted again")
+	}
+	for _, limit := range deliveries.limits {
+		if limit != 1 {
+			t.Errorf("claimed %d deliveries under one lease, want 1", limit)
+		}
+	}
+}
+
+func TestWebhookClientRefusesInternalAddresses(t *testing.T) {
+	for _, addr := range []string{"127.0.0.1", "::1", "169.254.169.254", "fe80::1", "0.0.0.0", "fd00:ec2::254", "::ffff:127.0.0.1", "10.0.0.1", "172.16.0.1", "192.168.1.1", "fd00::1", "100.64.0.1", "100.100.100.200", "0.1.2.3"} {
+		if webhookAddrAllowed(netip.MustParseAddr(addr)) {
+			t.Errorf("%s is allowed", addr)
+		}
+	}
+	for _, addr := range []string{"93.184.215.14", "2606:2800:21f:cb07:6820:80da:af6b:8b2c"} {
+		if !webhookAddrAllowed(netip.MustParseAddr(addr)) {
+			t.Errorf("%s is refused", addr)
+		}
+	}
+
+	hit := false
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/internal" {
+			hit = true
+		}
+		http.Redirect(w, r, "/internal", http.StatusFound)
+	}))
+	defer srv.Close()
+
+	app := newTestApplication(t)
+	app.webhookClient = newWebhookClient(time.Second)
+	delivery := &data.WebhookDelivery{ID: 1, Payload: []byte("{}"), URL: srv.URL}
+	_, err := app.sendWebhook(delivery)
+	if err == nil || !strings.Contains(err.Error(), "is not allowed") {
+		t.Errorf("delivery to a loopback receiver returned %v", err)
+	}
+
+	// Reuse the redirect policy with a transport that may reach the test
+	// server.
+	app.webhookClient.Transport = srv.Client().Transport
+	status, err := app.sendWebhook(delivery)
+	if status != http.StatusFound || err == nil {
+		t.Errorf("sendWebhook = %d, %v, want an unfollowed 302", status, err)
+	}
+	if hit {
+		t.Error("the redirect was followed")
+	}
+}
@@ -0,0 +1,69 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// MovieBatch is the set of movie writes that can share a transaction.
+type MovieBatch interface {
+	Get(id int64) (*Movie, error)
+	Insert(movie *Movie) error
+	Update(movie *Movie) error
+	Delete(id int64) error
+}
+
+type txMovieBatch struct {
+	ctx context.Context
+	tx  *sql.Tx
+}
+
+func (b txMovieBatch) Get(id int64) (*Movie, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+	movie := &Movie{}
+	err := scanMovie(b.tx.QueryRowContext(b.ctx, getMovieQuery+" FOR UPDATE", id), movie)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+	return movie, nil
+}
+
+func (b txMovieBatch) Insert(movie *Movie) error {
+	return insertMovie(b.ctx, b.tx, movie)
+}
+
+func (b txMovieBatch) Update(movie *Movie) error {
+	return updateMovie(b.ctx, b.tx, movie)
+}
+
+func (b txMovieBatch) Delete(id int64) error {
+	return deleteMovie(b.ctx, b.tx, id)
+}
+
+func (m MovieModel) Batch(fn func(b MovieBatch) error) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	err = fn(txMovieBatch{ctx: ctx, tx: tx})
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (m MockMovieModel) Batch(fn func(b MovieBatch) error) error {
+	return nil
+}
@@ -0,0 +1,300 @@
+package data
+
+import (
+	"container/list"
+	"encoding/json"
+	"maps"
+	"slices"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+type lruEntry[V any] struct {
+	key       string
+	value     V
+	expiresAt time.Time
+}
+
+type lruCache[V any] struct {
+	mu      sync.Mutex
+	size    int
+	order   *list.List
+	entries map[string]*list.Element
+}
+
+func newLRUCache[V any](size int) *lruCache[V] {
+	return &lruCache[V]{
+		size:    size,
+		order:   list.New(),
+		entries: make(map[string]*list.Element, size),
+	}
+}
+
+func (c *lruCache[V]) get(key string) (V, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var zero V
+	el, ok := c.entries[key]
+	if !ok {
+		return zero, false
+	}
+	entry := el.Value.(*lruEntry[V])
+	if time.Now().After(entry.expiresAt) {
+		c.order.Remove(el)
+		delete(c.entries, key)
+		return zero, false
+	}
+	c.order.MoveToFront(el)
+	return entry.value, true
+}
+
+func (c *lruCache[V]) set(key string, value V, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.entries[key]; ok {
+		entry := el.Value.(*lruEntry[V])
+		entry.value = value
+		entry.expiresAt = time.Now().Add(ttl)
+		c.order.MoveToFront(el)
+		return
+	}
+	c.entries[key] = c.order.PushFront(&lruEntry[V]{key: key, value: value, expiresAt: time.Now().Add(ttl)})
+	for c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*lruEntry[V]).key)
+	}
+}
+
+func (c *lruCache[V]) remove(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.entries[key]; ok {
+		c.order.Remove(el)
+		delete(c.entries, key)
+	}
+}
+
+func (c *lruCache[V]) purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.order.Init()
+	clear(c.entries)
+}
+
+func (c *lruCache[V]) len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
+
+type cachedList struct {
+	movies   []*Movie
+	metadata Metadata
+}
+
+type CacheStats struct {
+	Hits     int64 `json:"hits"`
+	Misses   int64 `json:"misses"`
+	Movies   int   `json:"movies"`
+	Lists    int   `json:"lists"`
+	Capacity int   `json:"capacity"`
+}
+
+type CachedMovieModel struct {
+	MovieStore
+	size       int
+	ttl        time.Duration
+	listTTL    time.Duration
+	movies     *lruCache[*Movie]
+	lists      *lruCache[cachedList]
+	group      singleflight.Group
+	generation atomic.Uint64
+	hits       atomic.Int64
+	misses     atomic.Int64
+}
+
+func NewCachedMovieModel(next MovieStore, size int, ttl, listTTL time.Duration) *CachedMovieModel {
+	return &CachedMovieModel{
+		MovieStore: next,
+		size:       size,
+		ttl:        ttl,
+		listTTL:    listTTL,
+		movies:     newLRUCache[*Movie](size),
+		lists:      newLRUCache[cachedList](size),
+	}
+}
+
+func cloneMovie(m *Movie) *Movie {
+	c := *m
+	c.Genres = slices.Clone(m.Genres)
+	c.ExternalIDs = maps.Clone(m.ExternalIDs)
+	if m.Images != nil {
+		c.Images = make([]*MovieImage, len(m.Images))
+		for i, image := range m.Images {
+			copied := *image
+			if image.Thumbnail != nil {
+				thumbnail := *image.Thumbnail
+				copied.Thumbnail = &thumbnail
+			}
+			c.Images[i] = &copied
+		}
+	}
+	if m.Releases != nil {
+		c.Releases = make([]*Release, len(m.Releases))
+		for i, release := range m.Releases {
+			copied := *release
+			c.Releases[i] = &copied
+		}
+	}
+	return &c
+}
+
+func cloneMovies(movies []*Movie) []*Movie {
+	clones := make([]*Movie, len(movies))
+	for i, m := range movies {
+		clones[i] = cloneMovie(m)
+	}
+	return clones
+}
+
+func (m *CachedMovieModel) Get(id int64) (*Movie, error) {
+	key := strconv.FormatInt(id, 10)
+	if movie, ok := m.movies.get(key); ok {
+		m.hits.Add(1)
+		return cloneMovie(movie), nil
+	}
+	m.misses.Add(1)
+	generation := m.generation.Load()
+	value, err, _ := m.group.Do("movie:"+key, func() (any, error) {
+		movie, err := m.MovieStore.Get(id)
+		if err != nil {
+			return nil, err
+		}
+		if m.generation.Load() == generation {
+			m.movies.set(key, movie, m.ttl)
+		}
+		return movie, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return cloneMovie(value.(*Movie)), nil
+}
+
+// GetFields serves a sparse fieldset from a cached movie when there is one,
+// and otherwise reads only the requested columns without caching them.
+func (m *CachedMovieModel) GetFields(id int64, fields []string) (*Movie, error) {
+	if len(fields) == 0 {
+		return m.Get(id)
+	}
+	if movie, ok := m.movies.get(strconv.FormatInt(id, 10)); ok {
+		m.hits.Add(1)
+		return cloneMovie(movie), nil
+	}
+	m.misses.Add(1)
+	return m.MovieStore.GetFields(id, fields)
+}
+
+func (m *CachedMovieModel) GetAll(search MovieSearch, filters Filters) ([]*Movie, Metadata, error) {
+	js, err := json.Marshal(struct {
+		Search  MovieSearch
+		Filters Filters
+	}{search, filters})
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	key := string(js)
+	if cached, ok := m.lists.get(key); ok {
+		m.hits.Add(1)
+		return cloneMovies(cached.movies), cached.metadata, nil
+	}
+	m.misses.Add(1)
+	generation := m.generation.Load()
+	value, err, _ := m.group.Do("list:"+key, func() (any, error) {
+		movies, metadata, err := m.MovieStore.GetAll(search, filters)
+		if err != nil {
+			return nil, err
+		}
+		cached := cachedList{movies: movies, metadata: metadata}
+		if m.generation.Load() == generation {
+			m.lists.set(key, cached, m.listTTL)
+		}
+		return cached, nil
+	})
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	cached := value.(cachedList)
+	return cloneMovies(cached.movies), cached.metadata, nil
+}
+
+func (m *CachedMovieModel) Insert(movie *Movie) error {
+	err := m.MovieStore.Insert(movie)
+	if err == nil {
+		m.invalidate()
+	}
+	return err
+}
+
+func (m *CachedMovieModel) Update(movie *Movie) error {
+	err := m.MovieStore.Update(movie)
+	m.invalidate(movie.ID)
+	return err
+}
+
+func (m *CachedMovieModel) Delete(id int64) error {
+	err := m.MovieStore.Delete(id)
+	m.invalidate(id)
+	return err
+}
+
+func (m *CachedMovieModel) Merge(survivorID, duplicateID int64) error {
+	err := m.MovieStore.Merge(survivorID, duplicateID)
+	m.invalidate(survivorID, duplicateID)
+	return err
+}
+
+func (m *CachedMovieModel) Batch(fn func(b MovieBatch) error) error {
+	err := m.MovieStore.Batch(fn)
+	if err == nil {
+		m.Purge()
+	}
+	return err
+}
+
+func (m *CachedMovieModel) invalidate(ids ...int64) {
+	m.generation.Add(1)
+	for _, id := range ids {
+		m.movies.remove(strconv.FormatInt(id, 10))
+	}
+	m.lists.purge()
+}
+
+func (m *CachedMovieModel) Purge() {
+	m.generation.Add(1)
+	m.movies.purge()
+	m.lists.purge()
+}
+
+func (m *CachedMovieModel) Invalidate(change MovieChange) {
+	if change.Op == MovieChangeResync {
+		m.Purge()
+		return
+	}
+	m.invalidate(change.ID)
+}
+
+func (m *CachedMovieModel) Stats() CacheStats {
+	return CacheStats{
+		Hits:     m.hits.Load(),
+		Misses:   m.misses.Load(),
+		Movies:   m.movies.len(),
+		Lists:    m.lists.len(),
+		Capacity: m.size,
+	}
+}
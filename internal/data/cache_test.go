@@ -0,0 +1,170 @@
+package data
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestLRUCacheEviction(t *testing.T) {
+	c := newLRUCache[int](2)
+	c.set("a", 1, time.Minute)
+	c.set("b", 2, time.Minute)
+	c.get("a")
+	c.set("c", 3, time.Minute)
+	if _, ok := c.get("b"); ok {
+		t.Error("the least recently used entry was not evicted")
+	}
+	for _, key := range []string{"a", "c"} {
+		if _, ok := c.get(key); !ok {
+			t.Errorf("%s was evicted", key)
+		}
+	}
+	if c.len() != 2 {
+		t.Errorf("len = %d, want 2", c.len())
+	}
+}
+
+func TestLRUCacheTTL(t *testing.T) {
+	c := newLRUCache[int](2)
+	c.set("fresh", 1, time.Minute)
+	c.set("stale", 2, -time.Second)
+	if _, ok := c.get("stale"); ok {
+		t.Error("an expired entry was served")
+	}
+	if _, ok := c.get("fresh"); !ok {
+		t.Error("a live entry was not served")
+	}
+	if c.len() != 1 {
+		t.Errorf("len = %d, want the expired entry to be dropped", c.len())
+	}
+}
+
+// countingMovies counts reads and can hold them until released.
+type countingMovies struct {
+	MockMovieModel
+	gets     atomic.Int32
+	fields   atomic.Int32
+	started  chan struct{}
+	release  chan struct{}
+	startOne sync.Once
+}
+
+func newCountingMovies(blocking bool) *countingMovies {
+	m := &countingMovies{started: make(chan struct{}), release: make(chan struct{})}
+	if !blocking {
+		close(m.release)
+	}
+	return m
+}
+
+func (m *countingMovies) Get(id int64) (*Movie, error) {
+	m.gets.Add(1)
+	m.startOne.Do(func() { close(m.started) })
+	<-m.release
+	return &Movie{ID: id, Title: "Alien", Genres: []string{"horror"}, Version: 1}, nil
+}
+
+func (m *countingMovies) GetFields(id int64, fields []string) (*Movie, error) {
+	m.fields.Add(1)
+	return &Movie{ID: id, Title: "Alien"}, nil
+}
+
+func TestCachedMovieModelCoalescesMisses(t *testing.T) {
+	store := newCountingMovies(true)
+	cache := NewCachedMovieModel(store, 10, time.Minute, time.Minute)
+	var wg sync.WaitGroup
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := cache.Get(1); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	<-store.started
+	time.Sleep(50 * time.Millisecond)
+	close(store.release)
+	wg.Wait()
+	if n := store.gets.Load(); n != 1 {
+		t.Errorf("store was read %d times, want 1", n)
+	}
+	if _, err := cache.Get(1); err != nil {
+		t.Fatal(err)
+	}
+	if n := store.gets.Load(); n != 1 {
+		t.Errorf("a cached movie was read from the store again")
+	}
+	if stats := cache.Stats(); stats.Hits < 1 || stats.Movies != 1 {
+		t.Errorf("stats = %+v", stats)
+	}
+}
+
+func TestCachedMovieModelGenerationGuard(t *testing.T) {
+	store := newCountingMovies(true)
+	cache := NewCachedMovieModel(store, 10, time.Minute, time.Minute)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		cache.Get(1)
+	}()
+	<-store.started
+	// The movie changes while the read is in flight, so its result is
+	// already stale and must not be cached.
+	cache.Invalidate(MovieChange{Op: MovieChangeUpdated, ID: 1})
+	close(store.release)
+	<-done
+	if _, err := cache.Get(1); err != nil {
+		t.Fatal(err)
+	}
+	if n := store.gets.Load(); n != 2 {
+		t.Errorf("store was read %d times, want 2", n)
+	}
+}
+
+func TestCachedMovieModelGetFields(t *testing.T) {
+	store := newCountingMovies(false)
+	cache := NewCachedMovieModel(store, 10, time.Minute, time.Minute)
+	if _, err := cache.GetFields(1, []string{"title"}); err != nil {
+		t.Fatal(err)
+	}
+	if store.fields.Load() != 1 || cache.Stats().Movies != 0 {
+		t.Error("a sparse read should pass through without being cached")
+	}
+	cache.Get(1)
+	movie, err := cache.GetFields(1, []string{"title"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if store.fields.Load() != 1 {
+		t.Error("a cached movie was not used for a sparse read")
+	}
+	movie.Genres[0] = "comedy"
+	cached, _ := cache.Get(1)
+	if cached.Genres[0] != "horror" {
+		t.Error("a caller mutated the cached movie")
+	}
+}
+
+func TestCloneMovieCopiesNestedValues(t *testing.T) {
+	movie := &Movie{
+		ID:       1,
+		Images:   []*MovieImage{{ID: 1, Kind: "poster", Thumbnail: &Thumbnail{Width: 200}}},
+		Releases: []*Release{{ID: 1, Country: "US", Type: "theatrical"}},
+	}
+	clone := cloneMovie(movie)
+	clone.Images[0].Kind = "backdrop"
+	clone.Images[0].Thumbnail.Width = 400
+	clone.Releases[0].Country = "GB"
+	if movie.Images[0].Kind != "poster" || movie.Images[0].Thumbnail.Width != 200 {
+		t.Errorf("image = %+v, want it unchanged", movie.Images[0])
+	}
+	if movie.Releases[0].Country != "US" {
+		t.Errorf("release = %+v, want it unchanged", movie.Releases[0])
+	}
+	if clone := cloneMovie(&Movie{ID: 2}); clone.Images != nil || clone.Releases != nil {
+		t.Error("a movie without images or releases gained empty slices")
+	}
+}
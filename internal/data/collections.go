@@ -0,0 +1,366 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/noonacedia/cinematrique/internal/validator"
+)
+
+var ErrUnknownCollectionMovie = errors.New("unknown collection movie")
+
+type Collection struct {
+	ID          int64     `json:"id"`
+	CreatedAt   time.Time `json:"-"`
+	Name        string    `json:"name"`
+	Description string    `json:"description,omitempty"`
+	MovieIDs    []int64   `json:"movie_ids"`
+	Version     int32     `json:"version"`
+}
+
+type CollectionSummary struct {
+	ID       int64  `json:"id"`
+	Name     string `json:"name"`
+	Position int    `json:"position"`
+	Size     int    `json:"size"`
+}
+
+type CollectionModel struct {
+	DB *sql.DB
+}
+
+func saveCollectionMovies(ctx context.Context, tx *sql.Tx, collection *Collection) error {
+	_, err := tx.ExecContext(ctx, `DELETE FROM collection_movies WHERE collection_id = $1`, collection.ID)
+	if err != nil {
+		return err
+	}
+	stmt := `
+		INSERT INTO collection_movies (collection_id, movie_id, position)
+		SELECT $1, movie_id, position FROM unnest($2::bigint[]) WITH ORDINALITY AS members (movie_id, position)
+	`
+	_, err = tx.ExecContext(ctx, stmt, collection.ID, pq.Array(collection.MovieIDs))
+	if err != nil {
+		var pqErr *pq.Error
+		switch {
+		case errors.As(err, &pqErr) && pqErr.Code == "23503":
+			return ErrUnknownCollectionMovie
+		default:
+			return err
+		}
+	}
+	return nil
+}
+
+func (m CollectionModel) Insert(collection *Collection) error {
+	stmt := `
+		INSERT INTO collections (name, description)
+		VALUES ($1, $2)
+		RETURNING id, created_at, version
+	`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	err = tx.QueryRowContext(ctx, stmt, collection.Name, collection.Description).Scan(&collection.ID, &collection.CreatedAt, &collection.Version)
+	if err != nil {
+		return err
+	}
+	err = saveCollectionMovies(ctx, tx, collection)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (m CollectionModel) Get(id int64) (*Collection, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+	stmt := `
+		SELECT id, created_at, name, description, version,
+			COALESCE((SELECT array_agg(movie_id ORDER BY position, movie_id) FROM collection_movies WHERE collection_id = collections.id), '{}')
+		FROM collections
+		WHERE id = $1
+	`
+	collection := &Collection{}
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	err := m.DB.QueryRowContext(ctx, stmt, id).Scan(
+		&collection.ID,
+		&collection.CreatedAt,
+		&collection.Name,
+		&collection.Description,
+		&collection.Version,
+		pq.Array(&collection.MovieIDs),
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+	return collection, nil
+}
+
+func (m CollectionModel) GetAll(name string, filters Filters) ([]*Collection, Metadata, error) {
+	stmt := fmt.Sprintf(`
+		SELECT COUNT(*) OVER(), id, created_at, name, description, version,
+			COALESCE((SELECT array_agg(movie_id ORDER BY position, movie_id) FROM collection_movies WHERE collection_id = collections.id), '{}')
+		FROM collections
+		WHERE ($1 = '' OR name ILIKE '%%' || $1 || '%%')
+		ORDER BY %s %s, id ASC
+		LIMIT $2 OFFSET $3`, filters.sortColumn(), filters.sortDirection())
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	rows, err := m.DB.QueryContext(ctx, stmt, name, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+	totalRecords := 0
+	collections := make([]*Collection, 0)
+	for rows.Next() {
+		var collection Collection
+		err := rows.Scan(
+			&totalRecords,
+			&collection.ID,
+			&collection.CreatedAt,
+			&collection.Name,
+			&collection.Description,
+			&collection.Version,
+			pq.Array(&collection.MovieIDs),
+		)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+		collections = append(collections, &collection)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+	return collections, metadata, nil
+}
+
+func (m CollectionModel) GetMovies(id int64, filters Filters) ([]*Movie, Metadata, error) {
+	stmt := fmt.Sprintf(`
+		SELECT COUNT(*) OVER(), movies.id, movies.created_at, movies.type, movies.title, movies.year, movies.runtime, movies.genres, movies.version
+		FROM collection_movies
+		JOIN movies ON movies.id = collection_movies.movie_id
+		WHERE collection_movies.collection_id = $1
+		ORDER BY %s %s, movies.id ASC
+		LIMIT $2 OFFSET $3`, filters.sortColumn(), filters.sortDirection())
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	rows, err := m.DB.QueryContext(ctx, stmt, id, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+	totalRecords := 0
+	movies := make([]*Movie, 0)
+	for rows.Next() {
+		var movie Movie
+		err := rows.Scan(
+			&totalRecords,
+			&movie.ID,
+			&movie.CreatedAt,
+			&movie.Type,
+			&movie.Title,
+			&movie.Year,
+			&movie.Runtime,
+			pq.Array(&movie.Genres),
+			&movie.Version,
+		)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+		movies = append(movies, &movie)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+	return movies, metadata, nil
+}
+
+func (m CollectionModel) GetSummaryForMovie(movieID int64) (*CollectionSummary, error) {
+	stmt := `
+		SELECT collections.id, collections.name, collection_movies.position,
+			(SELECT COUNT(*) FROM collection_movies size WHERE size.collection_id = collections.id)
+		FROM collection_movies
+		JOIN collections ON collections.id = collection_movies.collection_id
+		WHERE collection_movies.movie_id = $1
+		ORDER BY collections.id
+		LIMIT 1
+	`
+	var summary CollectionSummary
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	err := m.DB.QueryRowContext(ctx, stmt, movieID).Scan(&summary.ID, &summary.Name, &summary.Position, &summary.Size)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+	return &summary, nil
+}
+
+func (m CollectionModel) GetSummariesForMovies(movieIDs []int64) (map[int64]*CollectionSummary, error) {
+	stmt := `
+		SELECT DISTINCT ON (collection_movies.movie_id)
+			collection_movies.movie_id, collections.id, collections.name, collection_movies.position,
+			(SELECT COUNT(*) FROM collection_movies size WHERE size.collection_id = collections.id)
+		FROM collection_movies
+		JOIN collections ON collections.id = collection_movies.collection_id
+		WHERE collection_movies.movie_id = ANY($1)
+		ORDER BY collection_movies.movie_id, collections.id
+	`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	rows, err := m.DB.QueryContext(ctx, stmt, pq.Array(movieIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	summaries := make(map[int64]*CollectionSummary, len(movieIDs))
+	for rows.Next() {
+		var movieID int64
+		var summary CollectionSummary
+		err := rows.Scan(&movieID, &summary.ID, &summary.Name, &summary.Position, &summary.Size)
+		if err != nil {
+			return nil, err
+		}
+		summaries[movieID] = &summary
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return summaries, nil
+}
+
+func (m CollectionModel) Update(collection *Collection) error {
+	stmt := `
+		UPDATE collections
+		SET name = $1, description = $2, version = version + 1
+		WHERE id = $3 AND version = $4
+		RETURNING version
+	`
+	args := []any{collection.Name, collection.Description, collection.ID, collection.Version}
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	err = tx.QueryRowContext(ctx, stmt, args...).Scan(&collection.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+	err = saveCollectionMovies(ctx, tx, collection)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (m CollectionModel) Delete(id int64) error {
+	if id < 1 {
+		return ErrRecordNotFound
+	}
+	stmt := `
+		DELETE FROM collections
+		WHERE id = $1
+	`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	result, err := m.DB.ExecContext(ctx, stmt, id)
+	if err != nil {
+		return err
+	}
+	affectedRows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affectedRows == 0 {
+		return ErrRecordNotFound
+	}
+	return nil
+}
+
+type MockCollectionModel struct{}
+
+func (m MockCollectionModel) Insert(collection *Collection) error {
+	return nil
+}
+
+func (m MockCollectionModel) Get(id int64) (*Collection, error) {
+	return nil, nil
+}
+
+func (m MockCollectionModel) GetAll(name string, filters Filters) ([]*Collection, Metadata, error) {
+	return nil, Metadata{}, nil
+}
+
+func (m MockCollectionModel) GetMovies(id int64, filters Filters) ([]*Movie, Metadata, error) {
+	return nil, Metadata{}, nil
+}
+
+func (m MockCollectionModel) GetSummaryForMovie(movieID int64) (*CollectionSummary, error) {
+	return nil, ErrRecordNotFound
+}
+
+func (m MockCollectionModel) GetSummariesForMovies(movieIDs []int64) (map[int64]*CollectionSummary, error) {
+	return nil, nil
+}
+
+func (m MockCollectionModel) Update(collection *Collection) error {
+	return nil
+}
+
+func (m MockCollectionModel) Delete(id int64) error {
+	return nil
+}
+
+func ValidateCollection(v *validator.Validator, c *Collection) {
+	v.Check(c.Name != "", "name", "must be provided")
+	v.Check(len(c.Name) <= 500, "name", "must not be more than 500 bytes long")
+
+	v.Check(len(c.Description) <= 5_000, "description", "must not be more than 5000 bytes long")
+
+	v.Check(c.MovieIDs != nil, "movie_ids", "must be provided")
+	v.Check(len(c.MovieIDs) <= 1_000, "movie_ids", "must not contain more than 1000 movies")
+	for _, id := range c.MovieIDs {
+		v.Check(id > 0, "movie_ids", "must contain only positive ids")
+	}
+	v.Check(uniqueIDs(c.MovieIDs), "movie_ids", "must not contain duplicate values")
+}
+
+func uniqueIDs(ids []int64) bool {
+	seen := make(map[int64]bool, len(ids))
+	for _, id := range ids {
+		if seen[id] {
+			return false
+		}
+		seen[id] = true
+	}
+	return true
+}
@@ -0,0 +1,70 @@
+package data
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/lib/pq"
+)
+
+func TestCollectionPositions(t *testing.T) {
+	db := newTestDB(t)
+	m := CollectionModel{DB: db}
+	stmt := `INSERT INTO movies (title, year, runtime, genres) VALUES ($1, 1990, 100, '{drama}') RETURNING id`
+	ids := make([]int64, 3)
+	for i, title := range []string{"First", "Second", "Third"} {
+		if err := db.QueryRow(stmt, title).Scan(&ids[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Cleanup(func() { db.Exec(`DELETE FROM movies WHERE id = ANY($1)`, pq.Array(ids)) })
+
+	collection := &Collection{Name: "Positions", MovieIDs: []int64{ids[2], ids[0], ids[1]}}
+	err := m.Insert(collection)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { m.Delete(collection.ID) })
+
+	movieIDs := func() []int64 {
+		t.Helper()
+		movies, _, err := m.GetMovies(collection.ID, Filters{Page: 1, PageSize: 20, Sort: "position", SortSafelist: []string{"position"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := make([]int64, len(movies))
+		for i, movie := range movies {
+			got[i] = movie.ID
+		}
+		return got
+	}
+	if got, want := movieIDs(), collection.MovieIDs; !slices.Equal(got, want) {
+		t.Errorf("movies after insert = %v, want %v", got, want)
+	}
+
+	collection.MovieIDs = []int64{ids[1], ids[2]}
+	err = m.Update(collection)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := movieIDs(), collection.MovieIDs; !slices.Equal(got, want) {
+		t.Errorf("movies after reorder = %v, want %v", got, want)
+	}
+	stored, err := m.Get(collection.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(stored.MovieIDs, collection.MovieIDs) {
+		t.Errorf("Get movie_ids = %v, want %v", stored.MovieIDs, collection.MovieIDs)
+	}
+
+	collection.MovieIDs = []int64{ids[0], -1}
+	err = m.Update(collection)
+	if !errors.Is(err, ErrUnknownCollectionMovie) {
+		t.Errorf("Update with an unknown movie = %v, want ErrUnknownCollectionMovie", err)
+	}
+	if got := movieIDs(); !slices.Equal(got, []int64{ids[1], ids[2]}) {
+		t.Errorf("movies after failed update = %v, want them unchanged", got)
+	}
+}
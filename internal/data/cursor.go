@@ -0,0 +1,70 @@
+package data
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+type cursor struct {
+	Sort     string `json:"s"`
+	Key      string `json:"k"`
+	ID       int64  `json:"i"`
+	Backward bool   `json:"b,omitempty"`
+}
+
+func encodeCursor(c cursor, secret []byte) string {
+	payload, err := json.Marshal(c)
+	if err != nil {
+		panic(err)
+	}
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(payload)
+	return base64.RawURLEncoding.EncodeToString(payload) + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// cursor decodes the filters' cursor with the model's secret. A cursor signed
+// for a different sort is rejected like a tampered one.
+func (m MovieModel) cursor(f Filters) (*cursor, error) {
+	if f.Cursor == "" {
+		return nil, nil
+	}
+	c, err := decodeCursor(f.Cursor, m.CursorSecret)
+	if err != nil {
+		return nil, err
+	}
+	if c.Sort != f.Sort {
+		return nil, ErrInvalidCursor
+	}
+	return &c, nil
+}
+
+func decodeCursor(token string, secret []byte) (cursor, error) {
+	var c cursor
+	encodedPayload, encodedSignature, found := strings.Cut(token, ".")
+	if !found {
+		return c, ErrInvalidCursor
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encodedPayload)
+	if err != nil {
+		return c, ErrInvalidCursor
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(encodedSignature)
+	if err != nil {
+		return c, ErrInvalidCursor
+	}
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(payload)
+	if !hmac.Equal(signature, mac.Sum(nil)) {
+		return c, ErrInvalidCursor
+	}
+	if err := json.Unmarshal(payload, &c); err != nil {
+		return c, ErrInvalidCursor
+	}
+	return c, nil
+}
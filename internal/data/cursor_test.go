@@ -0,0 +1,70 @@
+package data
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	secret := []byte("secret")
+	want := cursor{Sort: "-year", Key: "1999", ID: 42, Backward: true}
+	got, err := decodeCursor(encodeCursor(want, secret), secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != want {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestCursorRejectsTampering(t *testing.T) {
+	secret := []byte("secret")
+	token := encodeCursor(cursor{Sort: "id", Key: "10", ID: 10}, secret)
+	payload, signature, _ := strings.Cut(token, ".")
+	forged := encodeCursor(cursor{Sort: "id", Key: "9000", ID: 9000}, secret)
+	forgedPayload, _, _ := strings.Cut(forged, ".")
+
+	tests := []struct {
+		name   string
+		token  string
+		secret []byte
+	}{
+		{"wrong secret", token, []byte("other")},
+		{"swapped payload", forgedPayload + "." + signature, secret},
+		{"truncated signature", payload + "." + signature[:len(signature)-2], secret},
+		{"missing signature", payload, secret},
+		{"not base64", "!!!." + signature, secret},
+		{"empty", ".", secret},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := decodeCursor(tt.token, tt.secret)
+			if !errors.Is(err, ErrInvalidCursor) {
+				t.Errorf("got %v, want ErrInvalidCursor", err)
+			}
+		})
+	}
+}
+
+func TestMovieModelCursor(t *testing.T) {
+	m := MovieModel{CursorSecret: []byte("secret")}
+	token := encodeCursor(cursor{Sort: "title", Key: "Alien", ID: 3}, m.CursorSecret)
+
+	c, err := m.cursor(Filters{Sort: "title", Cursor: token})
+	if err != nil || c == nil || c.ID != 3 {
+		t.Fatalf("got %+v, %v; want cursor for id 3", c, err)
+	}
+	c, err = m.cursor(Filters{Sort: "title"})
+	if err != nil || c != nil {
+		t.Errorf("empty cursor: got %+v, %v; want nil, nil", c, err)
+	}
+	_, err = m.cursor(Filters{Sort: "-title", Cursor: token})
+	if !errors.Is(err, ErrInvalidCursor) {
+		t.Errorf("sort mismatch: got %v, want ErrInvalidCursor", err)
+	}
+	_, err = MovieModel{CursorSecret: []byte("other")}.cursor(Filters{Sort: "title", Cursor: token})
+	if !errors.Is(err, ErrInvalidCursor) {
+		t.Errorf("other secret: got %v, want ErrInvalidCursor", err)
+	}
+}
@@ -0,0 +1,159 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+	"unicode"
+)
+
+var mergeStatements = []string{
+	`UPDATE movie_titles SET movie_id = $1
+	WHERE movie_id = $2 AND NOT EXISTS (
+		SELECT 1 FROM movie_titles survivor
+		WHERE survivor.movie_id = $1
+		AND survivor.locale = movie_titles.locale
+		AND survivor.kind = movie_titles.kind
+		AND survivor.title = movie_titles.title
+	)`,
+	`UPDATE movie_images SET movie_id = $1 WHERE movie_id = $2`,
+	`UPDATE movie_external_ids SET movie_id = $1
+	WHERE movie_id = $2 AND source NOT IN (SELECT source FROM movie_external_ids WHERE movie_id = $1)`,
+	`UPDATE releases SET movie_id = $1
+	WHERE movie_id = $2 AND (country, type) NOT IN (SELECT country, type FROM releases WHERE movie_id = $1)`,
+	`UPDATE collection_movies SET movie_id = $1
+	WHERE movie_id = $2 AND collection_id NOT IN (SELECT collection_id FROM collection_movies WHERE movie_id = $1)`,
+	`UPDATE seasons SET series_id = $1
+	WHERE series_id = $2 AND number NOT IN (SELECT number FROM seasons WHERE series_id = $1)`,
+	`UPDATE movie_redirects SET target_id = $1 WHERE target_id = $2`,
+	`INSERT INTO movie_redirects (movie_id, target_id) VALUES ($2, $1)`,
+	`DELETE FROM movies WHERE id = $2`,
+	`UPDATE movies SET version = version + 1 WHERE id = $1`,
+}
+
+func normalizeTitle(title string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToLower(r)
+		}
+		return -1
+	}, title)
+}
+
+func (m MovieModel) FindDuplicates(title string, year int32) ([]*Movie, error) {
+	stmt := `
+		SELECT id, created_at, title, year, runtime, genres, version, similarity(title, $1)::float8 AS score,
+			` + externalIDsColumn + `,
+			type
+		FROM movies
+		WHERE (lower(regexp_replace(title, '[^[:alnum:]]+', '', 'g')) = $2 AND year = $3)
+		OR (year BETWEEN $3 - 1 AND $3 + 1 AND similarity(title, $1) >= 0.6)
+		ORDER BY score DESC, id
+		LIMIT 5`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	rows, err := m.DB.QueryContext(ctx, stmt, title, normalizeTitle(title), year)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	movies := make([]*Movie, 0)
+	for rows.Next() {
+		var movie Movie
+		err := rows.Scan(movie.scanTargets(movieSelectColumns)...)
+		if err != nil {
+			return nil, err
+		}
+		movies = append(movies, &movie)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return movies, nil
+}
+
+func (m MovieModel) Merge(survivorID, duplicateID int64) error {
+	if survivorID < 1 || duplicateID < 1 {
+		return ErrRecordNotFound
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	rows, err := tx.QueryContext(ctx, `SELECT id FROM movies WHERE id IN ($1, $2) ORDER BY id FOR UPDATE`, survivorID, duplicateID)
+	if err != nil {
+		return err
+	}
+	locked := 0
+	for rows.Next() {
+		locked++
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if locked != 2 {
+		return ErrRecordNotFound
+	}
+	for _, stmt := range mergeStatements {
+		_, err := tx.ExecContext(ctx, stmt, survivorID, duplicateID)
+		if err != nil {
+			return err
+		}
+	}
+	err = notifyMovieChange(ctx, tx, MovieChangeDeleted, duplicateID, 0)
+	if err != nil {
+		return err
+	}
+	err = insertOutboxEvent(ctx, tx, "movie.deleted", map[string]any{"id": duplicateID, "merged_into": survivorID})
+	if err != nil {
+		return err
+	}
+	survivor := &Movie{}
+	err = scanMovie(tx.QueryRowContext(ctx, getMovieQuery, survivorID), survivor)
+	if err != nil {
+		return err
+	}
+	err = notifyMovieChange(ctx, tx, MovieChangeUpdated, survivor.ID, survivor.Version)
+	if err != nil {
+		return err
+	}
+	err = insertOutboxEvent(ctx, tx, "movie.updated", survivor)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (m MovieModel) GetRedirect(id int64) (int64, error) {
+	var target int64
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	err := m.DB.QueryRowContext(ctx, `SELECT target_id FROM movie_redirects WHERE movie_id = $1`, id).Scan(&target)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return 0, ErrRecordNotFound
+		default:
+			return 0, err
+		}
+	}
+	return target, nil
+}
+
+func (m MockMovieModel) FindDuplicates(title string, year int32) ([]*Movie, error) {
+	return nil, nil
+}
+
+func (m MockMovieModel) Merge(survivorID, duplicateID int64) error {
+	return nil
+}
+
+func (m MockMovieModel) GetRedirect(id int64) (int64, error) {
+	return 0, ErrRecordNotFound
+}
@@ -0,0 +1,178 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/noonacedia/cinematrique/internal/validator"
+)
+
+var ErrDuplicateExternalID = errors.New("duplicate external id")
+
+var ExternalIDSources = map[string]*regexp.Regexp{
+	"imdb":     regexp.MustCompile(`^tt\d{7,}$`),
+	"tmdb":     regexp.MustCompile(`^\d+$`),
+	"wikidata": regexp.MustCompile(`^Q\d+$`),
+}
+
+const externalIDsColumn = `COALESCE((SELECT jsonb_object_agg(source, value) FROM movie_external_ids WHERE movie_id = movies.id), '{}') AS external_ids`
+
+type DuplicateExternalIDError struct {
+	Source  string
+	Value   string
+	MovieID int64
+}
+
+func (e *DuplicateExternalIDError) Error() string {
+	return fmt.Sprintf("external id %s:%s already belongs to movie %d", e.Source, e.Value, e.MovieID)
+}
+
+func (e *DuplicateExternalIDError) Unwrap() error {
+	return ErrDuplicateExternalID
+}
+
+type jsonColumn struct {
+	dst any
+}
+
+func (c jsonColumn) Scan(src any) error {
+	switch src := src.(type) {
+	case nil:
+		return nil
+	case []byte:
+		return json.Unmarshal(src, c.dst)
+	case string:
+		return json.Unmarshal([]byte(src), c.dst)
+	default:
+		return fmt.Errorf("unsupported json column type %T", src)
+	}
+}
+
+// externalIDValueConstraint is the unique index that stops two movies
+// sharing an identifier. Postgres names it after the table and columns.
+const externalIDValueConstraint = "movie_external_ids_source_value_key"
+
+func saveExternalIDs(ctx context.Context, tx *sql.Tx, movie *Movie) error {
+	sources := make([]string, 0, len(movie.ExternalIDs))
+	values := make([]string, 0, len(movie.ExternalIDs))
+	for source, value := range movie.ExternalIDs {
+		sources = append(sources, source)
+		values = append(values, value)
+	}
+	err := externalIDOwner(ctx, tx, movie.ID, sources, values)
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, `DELETE FROM movie_external_ids WHERE movie_id = $1 AND NOT (source = ANY($2))`, movie.ID, pq.Array(sources))
+	if err != nil {
+		return err
+	}
+	// A concurrent transaction can claim the same identifier between the check
+	// above and the insert below. The savepoint keeps the transaction usable
+	// so that the owner of the conflicting key can still be looked up.
+	_, err = tx.ExecContext(ctx, `SAVEPOINT save_external_ids`)
+	if err != nil {
+		return err
+	}
+	stmt := `
+		INSERT INTO movie_external_ids (movie_id, source, value)
+		SELECT $1, source, value FROM unnest($2::text[], $3::text[]) AS ids (source, value)
+		ON CONFLICT (movie_id, source) DO UPDATE SET value = EXCLUDED.value
+	`
+	_, err = tx.ExecContext(ctx, stmt, movie.ID, pq.Array(sources), pq.Array(values))
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23505" && pqErr.Constraint == externalIDValueConstraint {
+			return externalIDConflict(ctx, tx, movie.ID, sources, values)
+		}
+		return err
+	}
+	return nil
+}
+
+// externalIDOwner returns a DuplicateExternalIDError if another movie
+// already holds any of the given identifiers.
+func externalIDOwner(ctx context.Context, tx *sql.Tx, movieID int64, sources, values []string) error {
+	stmt := `
+		SELECT movie_id, source, value
+		FROM movie_external_ids
+		WHERE (source, value) IN (SELECT * FROM unnest($1::text[], $2::text[]))
+		AND movie_id <> $3
+		ORDER BY source
+		LIMIT 1
+	`
+	var duplicate DuplicateExternalIDError
+	err := tx.QueryRowContext(ctx, stmt, pq.Array(sources), pq.Array(values), movieID).Scan(&duplicate.MovieID, &duplicate.Source, &duplicate.Value)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return nil
+	case err != nil:
+		return err
+	}
+	return &duplicate
+}
+
+// externalIDConflict turns a unique violation on movie_external_ids into a
+// DuplicateExternalIDError naming the movie that won the race. It falls back
+// to the bare ErrDuplicateExternalID if the owner has since let go of it.
+func externalIDConflict(ctx context.Context, tx *sql.Tx, movieID int64, sources, values []string) error {
+	_, err := tx.ExecContext(ctx, `ROLLBACK TO SAVEPOINT save_external_ids`)
+	if err != nil {
+		return err
+	}
+	err = externalIDOwner(ctx, tx, movieID, sources, values)
+	if err == nil {
+		return ErrDuplicateExternalID
+	}
+	return err
+}
+
+func (m MovieModel) GetByExternalID(source, value string) (*Movie, error) {
+	stmt := `
+		SELECT movie_id
+		FROM movie_external_ids
+		WHERE source = $1 AND value = $2
+	`
+	var id int64
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	err := m.DB.QueryRowContext(ctx, stmt, source, value).Scan(&id)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+	return m.Get(id)
+}
+
+func (m MockMovieModel) GetByExternalID(source, value string) (*Movie, error) {
+	return nil, nil
+}
+
+func ExternalIDSourceNames() []string {
+	names := make([]string, 0, len(ExternalIDSources))
+	for name := range ExternalIDSources {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+func ValidateExternalID(v *validator.Validator, source, value string) {
+	rx, ok := ExternalIDSources[source]
+	if !ok {
+		v.AddError("external_ids", fmt.Sprintf("unknown source %q", source))
+		return
+	}
+	v.Check(validator.Matches(value, rx), "external_ids", fmt.Sprintf("invalid %s identifier %q", source, value))
+}
@@ -0,0 +1,77 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestExternalIDValueConstraint(t *testing.T) {
+	db := newTestDB(t)
+	stmt := `
+		SELECT conname FROM pg_constraint
+		WHERE conrelid = 'movie_external_ids'::regclass AND contype = 'u'
+	`
+	var name string
+	if err := db.QueryRow(stmt).Scan(&name); err != nil {
+		t.Fatal(err)
+	}
+	if name != externalIDValueConstraint {
+		t.Errorf("unique constraint = %q, want %q", name, externalIDValueConstraint)
+	}
+}
+
+func TestSaveExternalIDsRace(t *testing.T) {
+	db := newTestDB(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	imdb := "tt" + time.Now().Format("150405000000")
+	newMovie := func(title string) *Movie {
+		return &Movie{Type: "movie", Title: title, Year: 1994, Runtime: 142, Genres: []string{"drama"}}
+	}
+	winner, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer winner.Rollback()
+	loser, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer loser.Rollback()
+
+	first := newMovie("Winner")
+	if err := insertMovie(ctx, winner, first); err != nil {
+		t.Fatal(err)
+	}
+	defer db.Exec(`DELETE FROM movies WHERE id = $1`, first.ID)
+	second := newMovie("Loser")
+	if err := insertMovie(ctx, loser, second); err != nil {
+		t.Fatal(err)
+	}
+	first.ExternalIDs = map[string]string{"imdb": imdb}
+	second.ExternalIDs = map[string]string{"imdb": imdb}
+	if err := saveExternalIDs(ctx, winner, first); err != nil {
+		t.Fatal(err)
+	}
+
+	// Both pre-insert checks pass; the loser's insert blocks on the unique
+	// index until the winner commits and then fails with 23505.
+	result := make(chan error, 1)
+	go func() { result <- saveExternalIDs(ctx, loser, second) }()
+	time.Sleep(100 * time.Millisecond)
+	if err := winner.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	err = <-result
+	var duplicate *DuplicateExternalIDError
+	if !errors.As(err, &duplicate) {
+		t.Fatalf("got %v, want a *DuplicateExternalIDError", err)
+	}
+	if duplicate.MovieID != first.ID || duplicate.Source != "imdb" || duplicate.Value != imdb {
+		t.Errorf("got %+v, want imdb:%s owned by movie %d", duplicate, imdb, first.ID)
+	}
+}
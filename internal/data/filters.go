@@ -12,11 +12,18 @@ type Filters struct {
 	PageSize     int
 	Sort         string
 	SortSafelist []string
+	UseCursor    bool
+	Cursor       string
+	SkipTotal    bool
+	Fields       []string
 }
 
 func (f Filters) sortColumn() string {
 	for _, safeSortValue := range f.SortSafelist {
 		if f.Sort == safeSortValue {
+			if f.Sort == "relevance" {
+				return "score"
+			}
 			return strings.TrimPrefix(f.Sort, "-")
 		}
 	}
@@ -24,12 +31,19 @@ func (f Filters) sortColumn() string {
 }
 
 func (f Filters) sortDirection() string {
-	if strings.HasPrefix(f.Sort, "-") {
+	if strings.HasPrefix(f.Sort, "-") || f.Sort == "relevance" {
 		return "DESC"
 	}
 	return "ASC"
 }
 
+func reverseDirection(direction string) string {
+	if direction == "DESC" {
+		return "ASC"
+	}
+	return "DESC"
+}
+
 func (f Filters) limit() int {
 	return f.PageSize
 }
@@ -44,14 +58,19 @@ func ValidateFilters(v *validator.Validator, f Filters) {
 	v.Check(f.PageSize > 0, "page_size", "must be positive")
 	v.Check(f.PageSize <= 100, "page_size", "must be less then 100")
 	v.Check(validator.In(f.Sort, f.SortSafelist...), "sort", "invalid sort value")
+	if f.UseCursor {
+		v.Check(f.Page == 1, "page", "cannot be combined with cursor")
+	}
 }
 
 type Metadata struct {
-	CurrentPage  int `json:"current_page,omitempty"`
-	PageSize     int `json:"page_size,omitempty"`
-	FirstPage    int `json:"first_page,omitempty"`
-	LastPage     int `json:"last_page,omitempty"`
-	TotalRecords int `json:"total_records,omitempty"`
+	CurrentPage  int    `json:"current_page,omitempty"`
+	PageSize     int    `json:"page_size,omitempty"`
+	FirstPage    int    `json:"first_page,omitempty"`
+	LastPage     int    `json:"last_page,omitempty"`
+	TotalRecords int    `json:"total_records,omitempty"`
+	NextCursor   string `json:"next_cursor,omitempty"`
+	PrevCursor   string `json:"prev_cursor,omitempty"`
 }
 
 func calculateMetadata(totalRecords, page, pageSize int) Metadata {
@@ -66,3 +85,12 @@ func calculateMetadata(totalRecords, page, pageSize int) Metadata {
 		TotalRecords: totalRecords,
 	}
 }
+
+func calculateCursorMetadata(totalRecords, pageSize int, next, prev string) Metadata {
+	return Metadata{
+		PageSize:     pageSize,
+		TotalRecords: totalRecords,
+		NextCursor:   next,
+		PrevCursor:   prev,
+	}
+}
@@ -0,0 +1,127 @@
+package data
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+type IdempotencyRecord struct {
+	Key         string
+	Fingerprint []byte
+	Status      int
+	Header      map[string][]string
+	Body        []byte
+}
+
+func (r *IdempotencyRecord) InFlight() bool {
+	return r.Status == 0
+}
+
+func (r *IdempotencyRecord) Matches(fingerprint []byte) bool {
+	return bytes.Equal(r.Fingerprint, fingerprint)
+}
+
+type IdempotencyModel struct {
+	DB *sql.DB
+}
+
+func (m IdempotencyModel) Begin(key string, fingerprint []byte, ttl, lockTimeout time.Duration) (*IdempotencyRecord, error) {
+	stmt := `
+		INSERT INTO idempotency_keys (key, fingerprint, expires_at)
+		VALUES ($1, $2, NOW() + $3 * interval '1 second')
+		ON CONFLICT (key) DO UPDATE
+		SET fingerprint = EXCLUDED.fingerprint, status = NULL, header = '{}', body = NULL,
+			created_at = NOW(), expires_at = EXCLUDED.expires_at
+		WHERE idempotency_keys.expires_at < NOW()
+		OR (idempotency_keys.status IS NULL AND idempotency_keys.created_at < NOW() - $4 * interval '1 second')
+		RETURNING key
+	`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	err := m.DB.QueryRowContext(ctx, stmt, key, fingerprint, ttl.Seconds(), lockTimeout.Seconds()).Scan(&key)
+	if err == nil {
+		return nil, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+	stmt = `
+		SELECT key, fingerprint, COALESCE(status, 0), header, COALESCE(body, '')
+		FROM idempotency_keys
+		WHERE key = $1
+	`
+	var record IdempotencyRecord
+	err = m.DB.QueryRowContext(ctx, stmt, key).Scan(
+		&record.Key,
+		&record.Fingerprint,
+		&record.Status,
+		jsonColumn{dst: &record.Header},
+		&record.Body,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+	return &record, nil
+}
+
+func (m IdempotencyModel) Complete(record *IdempotencyRecord) error {
+	header, err := json.Marshal(record.Header)
+	if err != nil {
+		return err
+	}
+	stmt := `
+		UPDATE idempotency_keys
+		SET status = $1, header = $2, body = $3
+		WHERE key = $4 AND fingerprint = $5 AND status IS NULL
+	`
+	args := []any{record.Status, header, record.Body, record.Key, record.Fingerprint}
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	_, err = m.DB.ExecContext(ctx, stmt, args...)
+	return err
+}
+
+func (m IdempotencyModel) Release(key string, fingerprint []byte) error {
+	stmt := `
+		DELETE FROM idempotency_keys
+		WHERE key = $1 AND fingerprint = $2 AND status IS NULL
+	`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	_, err := m.DB.ExecContext(ctx, stmt, key, fingerprint)
+	return err
+}
+
+func (m IdempotencyModel) DeleteExpired() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	_, err := m.DB.ExecContext(ctx, `DELETE FROM idempotency_keys WHERE expires_at < NOW()`)
+	return err
+}
+
+type MockIdempotencyModel struct{}
+
+func (m MockIdempotencyModel) Begin(key string, fingerprint []byte, ttl, lockTimeout time.Duration) (*IdempotencyRecord, error) {
+	return nil, nil
+}
+
+func (m MockIdempotencyModel) Complete(record *IdempotencyRecord) error {
+	return nil
+}
+
+func (m MockIdempotencyModel) Release(key string, fingerprint []byte) error {
+	return nil
+}
+
+func (m MockIdempotencyModel) DeleteExpired() error {
+	return nil
+}
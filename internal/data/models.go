@@ -3,6 +3,7 @@ package data
 import (
 	"database/sql"
 	"errors"
+	"time"
 )
 
 var (
@@ -10,14 +11,87 @@ var (
 	ErrEditConflict   = errors.New("edit conflict")
 )
 
+type MovieStore interface {
+	Insert(movie *Movie) error
+	Get(id int64) (*Movie, error)
+	GetFields(id int64, fields []string) (*Movie, error)
+	GetByExternalID(source, value string) (*Movie, error)
+	GetAll(search MovieSearch, filters Filters) ([]*Movie, Metadata, error)
+	Suggest(prefix string, limit int) ([]*MovieSuggestion, error)
+	GetSimilar(id int64, limit int) ([]*Movie, error)
+	RefreshSimilarities() error
+	Update(movie *Movie) error
+	Delete(id int64) error
+	FindDuplicates(title string, year int32) ([]*Movie, error)
+	Merge(survivorID, duplicateID int64) error
+	GetRedirect(id int64) (int64, error)
+	Batch(fn func(b MovieBatch) error) error
+}
+
 type Models struct {
-	Movies interface {
-		Insert(movie *Movie) error
-		Get(id int64) (*Movie, error)
-		GetAll(title string, genres []string, filters Filters) ([]*Movie, Metadata, error)
-		Update(movie *Movie) error
+	Movies      MovieStore
+	MovieTitles interface {
+		Insert(t *MovieTitle) error
+		GetAllForMovie(movieID int64) ([]*MovieTitle, error)
+		GetAllForMovies(movieIDs []int64) (map[int64][]*MovieTitle, error)
+		GetDisplayTitles(movieIDs []int64, locales []string) (map[int64]*MovieTitle, error)
+		Delete(id, movieID int64) error
+	}
+	MovieImages interface {
+		Insert(image *MovieImage) error
+		GetAllForMovies(movieIDs []int64) (map[int64][]*MovieImage, error)
+		SetThumbnail(id int64, thumbnail *Thumbnail) error
+	}
+	Releases interface {
+		Insert(release *Release) error
+		GetAllForMovies(movieIDs []int64) (map[int64][]*Release, error)
+		Delete(id, movieID int64) error
+	}
+	Collections interface {
+		Insert(collection *Collection) error
+		Get(id int64) (*Collection, error)
+		GetAll(name string, filters Filters) ([]*Collection, Metadata, error)
+		GetMovies(id int64, filters Filters) ([]*Movie, Metadata, error)
+		GetSummaryForMovie(movieID int64) (*CollectionSummary, error)
+		GetSummariesForMovies(movieIDs []int64) (map[int64]*CollectionSummary, error)
+		Update(collection *Collection) error
 		Delete(id int64) error
 	}
+	Series interface {
+		InsertSeason(season *Season) error
+		GetSeasons(seriesID int64) ([]*Season, error)
+		GetSeason(seriesID int64, number int32) (*Season, error)
+		InsertEpisode(episode *Episode) error
+		GetEpisode(seriesID int64, seasonNumber, number int32) (*Episode, error)
+	}
+	IdempotencyKeys interface {
+		Begin(key string, fingerprint []byte, ttl, lockTimeout time.Duration) (*IdempotencyRecord, error)
+		Complete(record *IdempotencyRecord) error
+		Release(key string, fingerprint []byte) error
+		DeleteExpired() error
+	}
+	Webhooks interface {
+		Insert(webhook *Webhook) error
+		Get(id int64) (*Webhook, error)
+		GetAll() ([]*Webhook, error)
+		Update(webhook *Webhook) error
+		Delete(id int64) error
+	}
+	WebhookDeliveries interface {
+		Enqueue(event string, payload []byte) error
+		Claim(limit int, lease time.Duration) ([]*WebhookDelivery, error)
+		RecordAttempt(delivery *WebhookDelivery) error
+		GetAllForWebhook(webhookID int64, status string, filters Filters) ([]*WebhookDelivery, Metadata, error)
+		Redeliver(id, webhookID int64) (*WebhookDelivery, error)
+	}
+	Outbox interface {
+		TryLead() (*OutboxLease, error)
+		GetUnpublished(limit int) ([]*OutboxEvent, error)
+		GetAfter(seq int64, limit int) ([]*OutboxEvent, error)
+		LatestSeq() (int64, error)
+		MarkPublished(ids []int64) error
+		DeletePublished(olderThan time.Duration) error
+	}
 	Users interface {
 		Insert(user *User) error
 		GetByEmail(email string) (*User, error)
@@ -25,16 +99,34 @@ type Models struct {
 	}
 }
 
-func NewModels(db *sql.DB) Models {
+func NewModels(db *sql.DB, cursorSecret []byte) Models {
 	return Models{
-		Movies: MovieModel{DB: db},
-		Users:  UserModel{DB: db},
+		Movies:            MovieModel{DB: db, CursorSecret: cursorSecret},
+		MovieTitles:       MovieTitleModel{DB: db},
+		MovieImages:       MovieImageModel{DB: db},
+		Releases:          ReleaseModel{DB: db},
+		Collections:       CollectionModel{DB: db},
+		Series:            SeriesModel{DB: db},
+		IdempotencyKeys:   IdempotencyModel{DB: db},
+		Webhooks:          WebhookModel{DB: db},
+		WebhookDeliveries: WebhookDeliveryModel{DB: db},
+		Outbox:            OutboxModel{DB: db},
+		Users:             UserModel{DB: db},
 	}
 }
 
 func NewMockModels() Models {
 	return Models{
-		Movies: MockMovieModel{},
-		Users:  MockUserModel{},
+		Movies:            MockMovieModel{},
+		MovieTitles:       MockMovieTitleModel{},
+		MovieImages:       MockMovieImageModel{},
+		Releases:          MockReleaseModel{},
+		Collections:       MockCollectionModel{},
+		Series:            MockSeriesModel{},
+		IdempotencyKeys:   MockIdempotencyModel{},
+		Webhooks:          MockWebhookModel{},
+		WebhookDeliveries: MockWebhookDeliveryModel{},
+		Outbox:            MockOutboxModel{},
+		Users:             MockUserModel{},
 	}
 }
@@ -0,0 +1,172 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/noonacedia/cinematrique/internal/validator"
+)
+
+var MovieImageKinds = []string{"poster", "still"}
+
+type Thumbnail struct {
+	Key    string `json:"-"`
+	URL    string `json:"url"`
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+}
+
+type MovieImage struct {
+	ID          int64      `json:"id"`
+	MovieID     int64      `json:"-"`
+	CreatedAt   time.Time  `json:"created_at"`
+	Kind        string     `json:"kind"`
+	ContentType string     `json:"content_type"`
+	Key         string     `json:"-"`
+	URL         string     `json:"url"`
+	Width       int        `json:"width"`
+	Height      int        `json:"height"`
+	Size        int64      `json:"size"`
+	Thumbnail   *Thumbnail `json:"thumbnail,omitempty"`
+}
+
+type MovieImageModel struct {
+	DB *sql.DB
+}
+
+func (m MovieImageModel) Insert(image *MovieImage) error {
+	stmt := `
+		INSERT INTO movie_images (movie_id, kind, content_type, key, width, height, size)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id, created_at
+	`
+	args := []any{image.MovieID, image.Kind, image.ContentType, image.Key, image.Width, image.Height, image.Size}
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	err = tx.QueryRowContext(ctx, stmt, args...).Scan(&image.ID, &image.CreatedAt)
+	if err != nil {
+		return err
+	}
+	err = notifyMovieChange(ctx, tx, MovieChangeUpdated, image.MovieID, 0)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (m MovieImageModel) GetAllForMovies(movieIDs []int64) (map[int64][]*MovieImage, error) {
+	images := make(map[int64][]*MovieImage)
+	if len(movieIDs) == 0 {
+		return images, nil
+	}
+	stmt := `
+		SELECT id, movie_id, created_at, kind, content_type, key, width, height, size,
+			thumbnail_key, thumbnail_width, thumbnail_height
+		FROM movie_images
+		WHERE movie_id = ANY($1)
+		ORDER BY movie_id, kind, id
+	`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	rows, err := m.DB.QueryContext(ctx, stmt, pq.Array(movieIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var image MovieImage
+		var thumbnailKey sql.NullString
+		var thumbnailWidth, thumbnailHeight sql.NullInt32
+		err := rows.Scan(
+			&image.ID,
+			&image.MovieID,
+			&image.CreatedAt,
+			&image.Kind,
+			&image.ContentType,
+			&image.Key,
+			&image.Width,
+			&image.Height,
+			&image.Size,
+			&thumbnailKey,
+			&thumbnailWidth,
+			&thumbnailHeight,
+		)
+		if err != nil {
+			return nil, err
+		}
+		if thumbnailKey.Valid {
+			image.Thumbnail = &Thumbnail{
+				Key:    thumbnailKey.String,
+				Width:  int(thumbnailWidth.Int32),
+				Height: int(thumbnailHeight.Int32),
+			}
+		}
+		images[image.MovieID] = append(images[image.MovieID], &image)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return images, nil
+}
+
+func (m MovieImageModel) SetThumbnail(id int64, thumbnail *Thumbnail) error {
+	stmt := `
+		UPDATE movie_images
+		SET thumbnail_key = $1, thumbnail_width = $2, thumbnail_height = $3
+		WHERE id = $4
+		RETURNING movie_id
+	`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	var movieID int64
+	err = tx.QueryRowContext(ctx, stmt, thumbnail.Key, thumbnail.Width, thumbnail.Height, id).Scan(&movieID)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrRecordNotFound
+		default:
+			return err
+		}
+	}
+	err = notifyMovieChange(ctx, tx, MovieChangeUpdated, movieID, 0)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+type MockMovieImageModel struct{}
+
+func (m MockMovieImageModel) Insert(image *MovieImage) error {
+	return nil
+}
+
+func (m MockMovieImageModel) GetAllForMovies(movieIDs []int64) (map[int64][]*MovieImage, error) {
+	return nil, nil
+}
+
+func (m MockMovieImageModel) SetThumbnail(id int64, thumbnail *Thumbnail) error {
+	return nil
+}
+
+func ValidateMovieImage(v *validator.Validator, image *MovieImage, maxSize, maxPixels int64) {
+	v.Check(validator.In(image.Kind, MovieImageKinds...), "kind", "must be one of poster or still")
+	v.Check(validator.In(image.ContentType, "image/jpeg", "image/png", "image/gif"), "file", "must be a JPEG, PNG or GIF image")
+	v.Check(image.Size > 0, "file", "must not be empty")
+	v.Check(image.Size <= maxSize, "file", "is too large")
+	v.Check(image.Width > 0 && image.Height > 0, "file", "must have valid dimensions")
+	v.Check(int64(image.Width)*int64(image.Height) <= maxPixels, "file", "has too many pixels")
+}
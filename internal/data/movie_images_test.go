@@ -0,0 +1,37 @@
+package data
+
+import (
+	"testing"
+
+	"github.com/noonacedia/cinematrique/internal/validator"
+)
+
+func TestValidateMovieImage(t *testing.T) {
+	tests := []struct {
+		name          string
+		width, height int
+		valid         bool
+	}{
+		{"within limit", 3_000, 2_000, true},
+		{"at limit", 10_000, 1_000, true},
+		{"over limit", 10_001, 1_000, false},
+		{"overflowing int32", 50_000, 50_000, false},
+		{"zero", 0, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			image := &MovieImage{
+				Kind:        "poster",
+				ContentType: "image/png",
+				Size:        1_024,
+				Width:       tt.width,
+				Height:      tt.height,
+			}
+			v := validator.New()
+			ValidateMovieImage(v, image, 10*1_024*1_024, 10_000_000)
+			if v.Valid() != tt.valid {
+				t.Errorf("valid = %t, want %t (errors %v)", v.Valid(), tt.valid, v.Errors)
+			}
+		})
+	}
+}
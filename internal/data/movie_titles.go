@@ -0,0 +1,247 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/noonacedia/cinematrique/internal/validator"
+)
+
+var ErrDuplicateMovieTitle = errors.New("duplicate movie title")
+
+var (
+	LocaleRx        = regexp.MustCompile(`^[a-z]{2,3}(-[a-z0-9]{2,8})*$`)
+	MovieTitleKinds = []string{"localized", "alternate", "original"}
+)
+
+var textSearchConfigs = map[string]string{
+	"da": "danish",
+	"de": "german",
+	"en": "english",
+	"es": "spanish",
+	"fi": "finnish",
+	"fr": "french",
+	"hu": "hungarian",
+	"it": "italian",
+	"nl": "dutch",
+	"no": "norwegian",
+	"pt": "portuguese",
+	"ro": "romanian",
+	"ru": "russian",
+	"sv": "swedish",
+	"tr": "turkish",
+}
+
+func textSearchConfig(locale string) string {
+	language, _, _ := strings.Cut(locale, "-")
+	if config, ok := textSearchConfigs[language]; ok {
+		return config
+	}
+	return "simple"
+}
+
+type MovieTitle struct {
+	ID      int64  `json:"id"`
+	MovieID int64  `json:"movie_id"`
+	Locale  string `json:"locale"`
+	Kind    string `json:"kind"`
+	Title   string `json:"title"`
+}
+
+type MovieTitleModel struct {
+	DB *sql.DB
+}
+
+func (m MovieTitleModel) Insert(t *MovieTitle) error {
+	stmt := `
+		INSERT INTO movie_titles (movie_id, locale, kind, title, ts_config)
+		VALUES ($1, $2, $3, $4, $5::regconfig)
+		RETURNING id
+	`
+	args := []any{t.MovieID, t.Locale, t.Kind, t.Title, textSearchConfig(t.Locale)}
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	err = tx.QueryRowContext(ctx, stmt, args...).Scan(&t.ID)
+	if err != nil {
+		var pqErr *pq.Error
+		switch {
+		case errors.As(err, &pqErr) && pqErr.Code == "23505":
+			return ErrDuplicateMovieTitle
+		default:
+			return err
+		}
+	}
+	err = notifyMovieChange(ctx, tx, MovieChangeUpdated, t.MovieID, 0)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (m MovieTitleModel) GetAllForMovie(movieID int64) ([]*MovieTitle, error) {
+	stmt := `
+		SELECT id, movie_id, locale, kind, title
+		FROM movie_titles
+		WHERE movie_id = $1
+		ORDER BY locale, kind, id
+	`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	rows, err := m.DB.QueryContext(ctx, stmt, movieID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	titles := make([]*MovieTitle, 0)
+	for rows.Next() {
+		var t MovieTitle
+		err := rows.Scan(&t.ID, &t.MovieID, &t.Locale, &t.Kind, &t.Title)
+		if err != nil {
+			return nil, err
+		}
+		titles = append(titles, &t)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return titles, nil
+}
+
+func (m MovieTitleModel) GetAllForMovies(movieIDs []int64) (map[int64][]*MovieTitle, error) {
+	titles := make(map[int64][]*MovieTitle)
+	if len(movieIDs) == 0 {
+		return titles, nil
+	}
+	stmt := `
+		SELECT id, movie_id, locale, kind, title
+		FROM movie_titles
+		WHERE movie_id = ANY($1)
+		ORDER BY movie_id, locale, kind, id
+	`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	rows, err := m.DB.QueryContext(ctx, stmt, pq.Array(movieIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var t MovieTitle
+		err := rows.Scan(&t.ID, &t.MovieID, &t.Locale, &t.Kind, &t.Title)
+		if err != nil {
+			return nil, err
+		}
+		titles[t.MovieID] = append(titles[t.MovieID], &t)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return titles, nil
+}
+
+func (m MovieTitleModel) GetDisplayTitles(movieIDs []int64, locales []string) (map[int64]*MovieTitle, error) {
+	titles := make(map[int64]*MovieTitle)
+	if len(movieIDs) == 0 || len(locales) == 0 {
+		return titles, nil
+	}
+	stmt := `
+		SELECT DISTINCT ON (movie_id) id, movie_id, locale, kind, title
+		FROM movie_titles
+		WHERE movie_id = ANY($1) AND kind = 'localized' AND locale = ANY($2)
+		ORDER BY movie_id, array_position($2, locale), id
+	`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	rows, err := m.DB.QueryContext(ctx, stmt, pq.Array(movieIDs), pq.Array(locales))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var t MovieTitle
+		err := rows.Scan(&t.ID, &t.MovieID, &t.Locale, &t.Kind, &t.Title)
+		if err != nil {
+			return nil, err
+		}
+		titles[t.MovieID] = &t
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return titles, nil
+}
+
+func (m MovieTitleModel) Delete(id, movieID int64) error {
+	if id < 1 {
+		return ErrRecordNotFound
+	}
+	stmt := `
+		DELETE FROM movie_titles
+		WHERE id = $1 AND movie_id = $2
+	`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	result, err := tx.ExecContext(ctx, stmt, id, movieID)
+	if err != nil {
+		return err
+	}
+	affectedRows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affectedRows == 0 {
+		return ErrRecordNotFound
+	}
+	err = notifyMovieChange(ctx, tx, MovieChangeUpdated, movieID, 0)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+type MockMovieTitleModel struct{}
+
+func (m MockMovieTitleModel) Insert(t *MovieTitle) error {
+	return nil
+}
+
+func (m MockMovieTitleModel) GetAllForMovie(movieID int64) ([]*MovieTitle, error) {
+	return nil, nil
+}
+
+func (m MockMovieTitleModel) GetAllForMovies(movieIDs []int64) (map[int64][]*MovieTitle, error) {
+	return nil, nil
+}
+
+func (m MockMovieTitleModel) GetDisplayTitles(movieIDs []int64, locales []string) (map[int64]*MovieTitle, error) {
+	return nil, nil
+}
+
+func (m MockMovieTitleModel) Delete(id, movieID int64) error {
+	return nil
+}
+
+func ValidateMovieTitle(v *validator.Validator, t *MovieTitle) {
+	v.Check(t.Title != "", "title", "must be provided")
+	v.Check(len(t.Title) <= 500, "title", "must not be more than 500 bytes long")
+
+	v.Check(t.Locale != "", "locale", "must be provided")
+	v.Check(validator.Matches(t.Locale, LocaleRx), "locale", "must be a valid language tag")
+
+	v.Check(validator.In(t.Kind, MovieTitleKinds...), "kind", "must be one of localized, alternate or original")
+}
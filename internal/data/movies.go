@@ -5,59 +5,162 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
+	"unicode"
 
 	"github.com/lib/pq"
 	"github.com/noonacedia/cinematrique/internal/validator"
 )
 
 type Movie struct {
-	ID        int64     `json:"id"`
-	CreatedAt time.Time `json:"-"`
-	Title     string    `json:"title"`
-	Year      int32     `json:"year,omitempty"`
-	Runtime   Runtime   `json:"runtime,omitempty"`
-	Genres    []string  `json:"genres,omitempty"`
-	Version   int32     `json:"version"`
+	ID            int64              `json:"id"`
+	CreatedAt     time.Time          `json:"-"`
+	Type          string             `json:"type"`
+	Title         string             `json:"title"`
+	OriginalTitle string             `json:"original_title,omitempty"`
+	Year          int32              `json:"year,omitempty"`
+	Runtime       Runtime            `json:"runtime,omitempty"`
+	Genres        []string           `json:"genres,omitempty"`
+	Version       int32              `json:"version"`
+	Score         float64            `json:"score,omitempty"`
+	Images        []*MovieImage      `json:"images,omitempty"`
+	ExternalIDs   map[string]string  `json:"external_ids,omitempty"`
+	Releases      []*Release         `json:"releases,omitempty"`
+	Collection    *CollectionSummary `json:"collection,omitempty"`
+}
+
+func (m *Movie) sortKey(column string) string {
+	switch column {
+	case "title":
+		return m.Title
+	case "year":
+		return strconv.FormatInt(int64(m.Year), 10)
+	case "runtime":
+		return strconv.FormatInt(int64(m.Runtime), 10)
+	case "score":
+		return strconv.FormatFloat(m.Score, 'g', -1, 64)
+	default:
+		return strconv.FormatInt(m.ID, 10)
+	}
+}
+
+var MovieFields = []string{"id", "type", "title", "original_title", "year", "runtime", "genres", "version", "score", "images", "external_ids", "collection"}
+
+var movieSelectColumns = []string{"id", "created_at", "title", "year", "runtime", "genres", "version", "score", "external_ids", "type"}
+
+func movieColumns(fields []string, sortColumn string) []string {
+	if len(fields) == 0 {
+		return movieSelectColumns
+	}
+	columns := []string{"id"}
+	for _, field := range slices.Concat(fields, []string{sortColumn}) {
+		if field == "original_title" {
+			field = "title"
+		}
+		if slices.Contains(movieSelectColumns, field) && !slices.Contains(columns, field) {
+			columns = append(columns, field)
+		}
+	}
+	return columns
+}
+
+func (m *Movie) scanTargets(columns []string) []any {
+	targets := make([]any, len(columns))
+	for i, column := range columns {
+		switch column {
+		case "id":
+			targets[i] = &m.ID
+		case "created_at":
+			targets[i] = &m.CreatedAt
+		case "title":
+			targets[i] = &m.Title
+		case "year":
+			targets[i] = &m.Year
+		case "runtime":
+			targets[i] = &m.Runtime
+		case "genres":
+			targets[i] = pq.Array(&m.Genres)
+		case "version":
+			targets[i] = &m.Version
+		case "score":
+			targets[i] = &m.Score
+		case "external_ids":
+			targets[i] = jsonColumn{dst: &m.ExternalIDs}
+		case "type":
+			targets[i] = &m.Type
+		default:
+			panic("unknown movie column: " + column)
+		}
+	}
+	return targets
+}
+
+type MovieSuggestion struct {
+	ID    int64  `json:"id"`
+	Title string `json:"title"`
+	Year  int32  `json:"year"`
 }
 
 type MovieModel struct {
-	DB *sql.DB
+	DB           *sql.DB
+	CursorSecret []byte
 }
 
-func (m MovieModel) Insert(movie *Movie) error {
+func insertMovie(ctx context.Context, tx *sql.Tx, movie *Movie) error {
 	stmt := `
-		INSERT INTO movies (title, year, runtime, genres)
-		VALUES ($1, $2, $3, $4)
+		INSERT INTO movies (type, title, year, runtime, genres)
+		VALUES ($1, $2, $3, $4, $5)
 		RETURNING id, created_at, version
 	`
-	args := []any{movie.Title, movie.Year, movie.Runtime, pq.Array(movie.Genres)}
+	args := []any{movie.Type, movie.Title, movie.Year, movie.Runtime, pq.Array(movie.Genres)}
+	err := tx.QueryRowContext(ctx, stmt, args...).Scan(&movie.ID, &movie.CreatedAt, &movie.Version)
+	if err != nil {
+		return err
+	}
+	err = saveExternalIDs(ctx, tx, movie)
+	if err != nil {
+		return err
+	}
+	for _, release := range movie.Releases {
+		release.MovieID = movie.ID
+		err = insertRelease(ctx, tx, release)
+		if err != nil {
+			return err
+		}
+	}
+	err = notifyMovieChange(ctx, tx, MovieChangeCreated, movie.ID, movie.Version)
+	if err != nil {
+		return err
+	}
+	return insertOutboxEvent(ctx, tx, "movie.created", movie)
+}
+
+func (m MovieModel) Insert(movie *Movie) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
-	return m.DB.QueryRowContext(ctx, stmt, args...).Scan(&movie.ID, &movie.CreatedAt, &movie.Version)
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	err = insertMovie(ctx, tx, movie)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
 }
 
 func (m MovieModel) Get(id int64) (*Movie, error) {
 	if id < 1 {
 		return nil, ErrRecordNotFound
 	}
-	stmt := `
-	SELECT id, created_at, title, year, runtime, genres, version
-	FROM movies
-	WHERE id = $1
-	`
 	movie := &Movie{}
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
-	err := m.DB.QueryRowContext(ctx, stmt, id).Scan(
-		&movie.ID,
-		&movie.CreatedAt,
-		&movie.Title,
-		&movie.Year,
-		&movie.Runtime,
-		pq.Array(&movie.Genres),
-		&movie.Version,
-	)
+	err := scanMovie(m.DB.QueryRowContext(ctx, getMovieQuery, id), movie)
 	if err != nil {
 		switch {
 		case errors.Is(err, sql.ErrNoRows):
@@ -69,17 +172,149 @@ func (m MovieModel) Get(id int64) (*Movie, error) {
 	return movie, nil
 }
 
-func (m MovieModel) GetAll(title string, genres []string, filters Filters) ([]*Movie, Metadata, error) {
-	stmt := fmt.Sprintf(`
-		SELECT COUNT(*) OVER(), id, created_at, title, year, runtime, genres, version
+// GetFields fetches only the columns needed to render fields, like GetAll
+// does for a sparse fieldset. No fields means the whole movie.
+func (m MovieModel) GetFields(id int64, fields []string) (*Movie, error) {
+	if len(fields) == 0 {
+		return m.Get(id)
+	}
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+	columns := movieColumns(fields, "id")
+	movie := &Movie{}
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	err := m.DB.QueryRowContext(ctx, getMovieFieldsQuery(columns), id).Scan(movie.scanTargets(columns)...)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+	return movie, nil
+}
+
+func getMovieFieldsQuery(columns []string) string {
+	exprs := make([]string, len(columns))
+	for i, column := range columns {
+		switch column {
+		case "external_ids":
+			exprs[i] = externalIDsColumn
+		case "score":
+			exprs[i] = "0::float8 AS score"
+		default:
+			exprs[i] = column
+		}
+	}
+	return fmt.Sprintf("SELECT %s FROM movies WHERE id = $1", strings.Join(exprs, ", "))
+}
+
+const getMovieQuery = `
+	SELECT id, created_at, type, title, year, runtime, genres, version, ` + externalIDsColumn + `
+	FROM movies
+	WHERE id = $1
+	`
+
+func scanMovie(row *sql.Row, movie *Movie) error {
+	return row.Scan(
+		&movie.ID,
+		&movie.CreatedAt,
+		&movie.Type,
+		&movie.Title,
+		&movie.Year,
+		&movie.Runtime,
+		pq.Array(&movie.Genres),
+		&movie.Version,
+		jsonColumn{dst: &movie.ExternalIDs},
+	)
+}
+
+func prefixQuery(title string) string {
+	words := strings.FieldsFunc(title, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	for i, word := range words {
+		words[i] = word + ":*"
+	}
+	return strings.Join(words, " & ")
+}
+
+type MovieSearch struct {
+	Title          string
+	Type           string
+	Genres         []string
+	ReleasedIn     string
+	ReleasedAfter  time.Time
+	ReleasedBefore time.Time
+}
+
+func nullTime(t time.Time) any {
+	if t.IsZero() {
+		return nil
+	}
+	return t
+}
+
+func searchMoviesQuery(search MovieSearch) (string, []any) {
+	stmt := `
+		SELECT id, created_at, title, year, runtime, genres, version,
+			(ts_rank(to_tsvector('simple', title), to_tsquery('simple', $3)) + GREATEST(
+				word_similarity($1, title),
+				(SELECT COALESCE(max(word_similarity($1, mt.title)), 0) FROM movie_titles mt WHERE mt.movie_id = movies.id)
+			))::float8 AS score,
+			` + externalIDsColumn + `,
+			type
 		FROM movies
-		WHERE (to_tsvector('simple', title) @@ plainto_tsquery('simple', $1) OR $1 = '')
+		WHERE ($1 = '' OR to_tsvector('simple', title) @@ to_tsquery('simple', $3) OR $1 <% title OR EXISTS (
+			SELECT 1 FROM movie_titles mt
+			WHERE mt.movie_id = movies.id
+			AND (to_tsvector(mt.ts_config, mt.title) @@ plainto_tsquery(mt.ts_config, $1)
+				OR to_tsvector('simple', mt.title) @@ to_tsquery('simple', $3)
+				OR $1 <% mt.title)
+		))
 		AND (genres @> $2 OR $2 = '{}')
+		AND ($7 = '' OR type = $7)
+		AND (($4 = '' AND $5::date IS NULL AND $6::date IS NULL) OR EXISTS (
+			SELECT 1 FROM releases r
+			WHERE r.movie_id = movies.id
+			AND ($4 = '' OR r.country = $4)
+			AND ($5::date IS NULL OR r.date >= $5::date)
+			AND ($6::date IS NULL OR r.date <= $6::date)
+		))`
+	args := []any{
+		search.Title,
+		pq.Array(search.Genres),
+		prefixQuery(search.Title),
+		search.ReleasedIn,
+		nullTime(search.ReleasedAfter),
+		nullTime(search.ReleasedBefore),
+		search.Type,
+	}
+	return stmt, args
+}
+
+func (m MovieModel) GetAll(search MovieSearch, filters Filters) ([]*Movie, Metadata, error) {
+	if filters.UseCursor {
+		return m.getAllByCursor(search, filters)
+	}
+	query, args := searchMoviesQuery(search)
+	columns := movieColumns(filters.Fields, filters.sortColumn())
+	total := "COUNT(*) OVER()"
+	if filters.SkipTotal {
+		total = "0"
+	}
+	stmt := fmt.Sprintf(`
+		SELECT %s, %s
+		FROM (%s) AS movies
 		ORDER BY %s %s, id ASC
-		LIMIT $3 OFFSET $4`, filters.sortColumn(), filters.sortDirection())
+		LIMIT $%d OFFSET $%d`, total, strings.Join(columns, ", "), query, filters.sortColumn(), filters.sortDirection(), len(args)+1, len(args)+2)
+	args = append(args, filters.limit(), filters.offset())
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
-	rows, err := m.DB.QueryContext(ctx, stmt, title, pq.Array(genres), filters.limit(), filters.offset())
+	rows, err := m.DB.QueryContext(ctx, stmt, args...)
 	if err != nil {
 		return nil, Metadata{}, err
 	}
@@ -88,16 +323,7 @@ func (m MovieModel) GetAll(title string, genres []string, filters Filters) ([]*M
 	movies := make([]*Movie, 0)
 	for rows.Next() {
 		var movie Movie
-		err := rows.Scan(
-			&totalRecords,
-			&movie.ID,
-			&movie.CreatedAt,
-			&movie.Title,
-			&movie.Year,
-			&movie.Runtime,
-			pq.Array(&movie.Genres),
-			&movie.Version,
-		)
+		err := rows.Scan(append([]any{&totalRecords}, movie.scanTargets(columns)...)...)
 		if err != nil {
 			return nil, Metadata{}, err
 		}
@@ -106,11 +332,165 @@ func (m MovieModel) GetAll(title string, genres []string, filters Filters) ([]*M
 	if err := rows.Err(); err != nil {
 		return nil, Metadata{}, err
 	}
+	if filters.SkipTotal {
+		return movies, Metadata{CurrentPage: filters.Page, PageSize: filters.PageSize, FirstPage: 1}, nil
+	}
 	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
 	return movies, metadata, nil
 }
 
-func (m MovieModel) Update(movie *Movie) error {
+func (m MovieModel) getAllByCursor(search MovieSearch, filters Filters) ([]*Movie, Metadata, error) {
+	c, err := m.cursor(filters)
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	query, args := searchMoviesQuery(search)
+	column, direction := filters.sortColumn(), filters.sortDirection()
+	columns := movieColumns(filters.Fields, column)
+	backward := c != nil && c.Backward
+	if backward {
+		direction = reverseDirection(direction)
+	}
+	keyset := "TRUE"
+	if c != nil {
+		operator := ">"
+		if direction == "DESC" {
+			operator = "<"
+		}
+		keyset = fmt.Sprintf("(%s, id) %s ($%d, $%d)", column, operator, len(args)+1, len(args)+2)
+	}
+	stmt := fmt.Sprintf(`
+		SELECT %s
+		FROM (%s) AS movies
+		WHERE %s
+		ORDER BY %s %s, id %s
+		LIMIT %d`, strings.Join(columns, ", "), query, keyset, column, direction, direction, filters.limit()+1)
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	queryArgs := args
+	if c != nil {
+		queryArgs = append(slices.Clip(args), c.Key, c.ID)
+	}
+	rows, err := m.DB.QueryContext(ctx, stmt, queryArgs...)
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+	movies := make([]*Movie, 0, filters.limit()+1)
+	for rows.Next() {
+		var movie Movie
+		err := rows.Scan(movie.scanTargets(columns)...)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+		movies = append(movies, &movie)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+	hasMore := len(movies) > filters.limit()
+	if hasMore {
+		movies = movies[:filters.limit()]
+	}
+	if backward {
+		slices.Reverse(movies)
+	}
+	var next, prev string
+	if len(movies) > 0 {
+		first, last := movies[0], movies[len(movies)-1]
+		if hasMore || backward {
+			next = encodeCursor(cursor{Sort: filters.Sort, Key: last.sortKey(column), ID: last.ID}, m.CursorSecret)
+		}
+		if (backward && hasMore) || (!backward && c != nil) {
+			prev = encodeCursor(cursor{Sort: filters.Sort, Key: first.sortKey(column), ID: first.ID, Backward: true}, m.CursorSecret)
+		}
+	}
+	totalRecords := 0
+	if !filters.SkipTotal {
+		stmt := fmt.Sprintf(`SELECT COUNT(*) FROM (%s) AS movies`, query)
+		err := m.DB.QueryRowContext(ctx, stmt, args...).Scan(&totalRecords)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+	}
+	metadata := calculateCursorMetadata(totalRecords, filters.PageSize, next, prev)
+	return movies, metadata, nil
+}
+
+func (m MovieModel) Suggest(prefix string, limit int) ([]*MovieSuggestion, error) {
+	suggestions := make([]*MovieSuggestion, 0, limit)
+	query := prefixQuery(prefix)
+	if query == "" {
+		return suggestions, nil
+	}
+	pattern := strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(strings.ToLower(prefix)) + "%"
+	stmt := `
+		SELECT id, title, year
+		FROM movies
+		WHERE lower(title) LIKE $2 OR to_tsvector('simple', title) @@ to_tsquery('simple', $1)
+		ORDER BY lower(title) LIKE $2 DESC, length(title), id
+		LIMIT $3`
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	rows, err := m.DB.QueryContext(ctx, stmt, query, pattern, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var suggestion MovieSuggestion
+		err := rows.Scan(&suggestion.ID, &suggestion.Title, &suggestion.Year)
+		if err != nil {
+			return nil, err
+		}
+		suggestions = append(suggestions, &suggestion)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return suggestions, nil
+}
+
+func (m MovieModel) GetSimilar(id int64, limit int) ([]*Movie, error) {
+	stmt := `
+		SELECT movies.id, movies.created_at, movies.title, movies.year, movies.runtime, movies.genres, movies.version, s.score,
+			` + externalIDsColumn + `,
+			movies.type
+		FROM movie_similarities s
+		JOIN movies ON movies.id = s.similar_id
+		WHERE s.movie_id = $1
+		ORDER BY s.score DESC, movies.id
+		LIMIT $2`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	rows, err := m.DB.QueryContext(ctx, stmt, id, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	movies := make([]*Movie, 0, limit)
+	for rows.Next() {
+		var movie Movie
+		err := rows.Scan(movie.scanTargets(movieSelectColumns)...)
+		if err != nil {
+			return nil, err
+		}
+		movies = append(movies, &movie)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return movies, nil
+}
+
+func (m MovieModel) RefreshSimilarities() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+	_, err := m.DB.ExecContext(ctx, `REFRESH MATERIALIZED VIEW CONCURRENTLY movie_similarities`)
+	return err
+}
+
+func updateMovie(ctx context.Context, tx *sql.Tx, movie *Movie) error {
 	stmt := `
 	UPDATE movies
 	SET title = $1, year = $2, runtime = $3, genres = $4, version = version + 1
@@ -118,9 +498,7 @@ func (m MovieModel) Update(movie *Movie) error {
 	RETURNING version
 	`
 	args := []any{movie.Title, movie.Year, movie.Runtime, pq.Array(movie.Genres), movie.ID, movie.Version}
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
-	defer cancel()
-	err := m.DB.QueryRowContext(ctx, stmt, args...).Scan(&movie.Version)
+	err := tx.QueryRowContext(ctx, stmt, args...).Scan(&movie.Version)
 	if err != nil {
 		switch {
 		case errors.Is(err, sql.ErrNoRows):
@@ -129,10 +507,33 @@ func (m MovieModel) Update(movie *Movie) error {
 			return err
 		}
 	}
-	return nil
+	err = saveExternalIDs(ctx, tx, movie)
+	if err != nil {
+		return err
+	}
+	err = notifyMovieChange(ctx, tx, MovieChangeUpdated, movie.ID, movie.Version)
+	if err != nil {
+		return err
+	}
+	return insertOutboxEvent(ctx, tx, "movie.updated", movie)
 }
 
-func (m MovieModel) Delete(id int64) error {
+func (m MovieModel) Update(movie *Movie) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	err = updateMovie(ctx, tx, movie)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func deleteMovie(ctx context.Context, tx *sql.Tx, id int64) error {
 	if id < 1 {
 		return ErrRecordNotFound
 	}
@@ -140,9 +541,7 @@ func (m MovieModel) Delete(id int64) error {
 	DELETE FROM movies
 	WHERE id = $1
 	`
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
-	defer cancel()
-	result, err := m.DB.ExecContext(ctx, stmt, id)
+	result, err := tx.ExecContext(ctx, stmt, id)
 	if err != nil {
 		return err
 	}
@@ -153,7 +552,26 @@ func (m MovieModel) Delete(id int64) error {
 	if affectedRows == 0 {
 		return ErrRecordNotFound
 	}
-	return nil
+	err = notifyMovieChange(ctx, tx, MovieChangeDeleted, id, 0)
+	if err != nil {
+		return err
+	}
+	return insertOutboxEvent(ctx, tx, "movie.deleted", map[string]any{"id": id})
+}
+
+func (m MovieModel) Delete(id int64) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	err = deleteMovie(ctx, tx, id)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
 }
 
 type MockMovieModel struct{}
@@ -166,10 +584,26 @@ func (m MockMovieModel) Get(id int64) (*Movie, error) {
 	return nil, nil
 }
 
-func (m MockMovieModel) GetAll(title string, genres []string, filters Filters) ([]*Movie, Metadata, error) {
+func (m MockMovieModel) GetFields(id int64, fields []string) (*Movie, error) {
+	return nil, nil
+}
+
+func (m MockMovieModel) GetAll(search MovieSearch, filters Filters) ([]*Movie, Metadata, error) {
 	return nil, Metadata{}, nil
 }
 
+func (m MockMovieModel) Suggest(prefix string, limit int) ([]*MovieSuggestion, error) {
+	return nil, nil
+}
+
+func (m MockMovieModel) GetSimilar(id int64, limit int) ([]*Movie, error) {
+	return nil, nil
+}
+
+func (m MockMovieModel) RefreshSimilarities() error {
+	return nil
+}
+
 func (m MockMovieModel) Update(movie *Movie) error {
 	return nil
 }
@@ -186,11 +620,33 @@ func ValidateMovie(v *validator.Validator, m *Movie) {
 	v.Check(m.Year >= 1888, "year", "must be greater than 1888")
 	v.Check(m.Year <= int32(time.Now().Year()), "year", "must not be in the future")
 
-	v.Check(m.Runtime != 0, "runtime", "must be provided")
-	v.Check(m.Runtime > 0, "runtime", "must be a positive integer")
+	v.Check(validator.In(m.Type, MovieTypes...), "type", "must be one of: "+strings.Join(MovieTypes, ", "))
+
+	if m.Type == "series" {
+		v.Check(m.Runtime >= 0, "runtime", "must not be negative")
+	} else {
+		v.Check(m.Runtime != 0, "runtime", "must be provided")
+		v.Check(m.Runtime > 0, "runtime", "must be a positive integer")
+	}
 
 	v.Check(m.Genres != nil, "genres", "must be provided")
 	v.Check(len(m.Genres) >= 1, "genres", "must contain at least 1 genre")
 	v.Check(len(m.Genres) <= 5, "genres", "must not contain more than 5 genres")
 	v.Check(validator.Unique(m.Genres), "genres", "must not contain duplicate values")
+
+	for source, value := range m.ExternalIDs {
+		ValidateExternalID(v, source, value)
+	}
+
+	for i, release := range m.Releases {
+		if release == nil {
+			v.AddError(fmt.Sprintf("releases[%d]", i), "must not be null")
+			continue
+		}
+		rv := validator.New()
+		ValidateRelease(rv, release)
+		for key, msg := range rv.Errors {
+			v.AddError(fmt.Sprintf("releases[%d].%s", i, key), msg)
+		}
+	}
 }
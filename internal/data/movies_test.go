@@ -0,0 +1,107 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGetMovieFieldsQuery(t *testing.T) {
+	tests := []struct {
+		fields []string
+		want   string
+	}{
+		{[]string{"title"}, "SELECT id, title FROM movies WHERE id = $1"},
+		{[]string{"original_title", "year"}, "SELECT id, title, year FROM movies WHERE id = $1"},
+		{[]string{"images", "runtime"}, "SELECT id, runtime FROM movies WHERE id = $1"},
+		{[]string{"external_ids"}, "SELECT id, " + externalIDsColumn + " FROM movies WHERE id = $1"},
+	}
+	for _, tt := range tests {
+		t.Run(strings.Join(tt.fields, ","), func(t *testing.T) {
+			got := getMovieFieldsQuery(movieColumns(tt.fields, "id"))
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPrefixQuery(t *testing.T) {
+	tests := []struct {
+		prefix string
+		want   string
+	}{
+		{"ali", "ali:*"},
+		{"star wa", "star:* & wa:*"},
+		{"  l'été  ", "l:* & été:*"},
+		{"2001: a", "2001:* & a:*"},
+		{"!&|:*", ""},
+	}
+	for _, tt := range tests {
+		if got := prefixQuery(tt.prefix); got != tt.want {
+			t.Errorf("prefixQuery(%q) = %q, want %q", tt.prefix, got, tt.want)
+		}
+	}
+}
+
+func TestSuggestTimesOut(t *testing.T) {
+	m := MovieModel{DB: newStallingDB(t)}
+	start := time.Now()
+	_, err := m.Suggest("ali", 5)
+	elapsed := time.Since(start)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("err = %v, want context.DeadlineExceeded", err)
+	}
+	if elapsed < 400*time.Millisecond || elapsed > 2*time.Second {
+		t.Errorf("Suggest gave up after %v, want about 500ms", elapsed)
+	}
+}
+
+func TestSuggestSkipsQueriesWithoutWords(t *testing.T) {
+	m := MovieModel{DB: newStallingDB(t)}
+	suggestions, err := m.Suggest("?!", 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if suggestions == nil || len(suggestions) != 0 {
+		t.Errorf("suggestions = %v, want an empty list", suggestions)
+	}
+}
+
+func TestGetSimilar(t *testing.T) {
+	db := newTestDB(t)
+	m := MovieModel{DB: db}
+	stmt := `INSERT INTO movies (title, year, runtime, genres) VALUES ($1, $2, 100, $3) RETURNING id`
+	ids := make([]int64, 3)
+	for i, genres := range []string{"{similar-test-a,similar-test-b}", "{similar-test-a,similar-test-b}", "{similar-test-a}"} {
+		if err := db.QueryRow(stmt, "Similar "+genres, 1990, genres).Scan(&ids[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Cleanup(func() {
+		db.Exec(`DELETE FROM movies WHERE id IN ($1, $2, $3)`, ids[0], ids[1], ids[2])
+		m.RefreshSimilarities()
+	})
+	err := m.RefreshSimilarities()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	similar, err := m.GetSimilar(ids[0], 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(similar) != 1 || similar[0].ID != ids[1] {
+		t.Errorf("GetSimilar(%d, 1) = %v, want only the movie sharing both genres (%d)", ids[0], similar, ids[1])
+	}
+
+	similar, err = m.GetSimilar(-1, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if similar == nil || len(similar) != 0 {
+		t.Errorf("GetSimilar for an unknown movie = %#v, want an empty list", similar)
+	}
+}
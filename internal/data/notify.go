@@ -0,0 +1,34 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+)
+
+const (
+	MovieChangedChannel    = "movie_changed"
+	OutboxPublishedChannel = "outbox_published"
+)
+
+const (
+	MovieChangeCreated = "created"
+	MovieChangeUpdated = "updated"
+	MovieChangeDeleted = "deleted"
+	MovieChangeResync  = "resync"
+)
+
+type MovieChange struct {
+	Op      string `json:"op"`
+	ID      int64  `json:"id"`
+	Version int32  `json:"version"`
+}
+
+func notifyMovieChange(ctx context.Context, tx *sql.Tx, op string, id int64, version int32) error {
+	payload, err := json.Marshal(MovieChange{Op: op, ID: id, Version: version})
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, `SELECT pg_notify($1, $2)`, MovieChangedChannel, string(payload))
+	return err
+}
@@ -0,0 +1,216 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+const (
+	outboxLockKey        = 7_210_001
+	outboxPublishLockKey = 7_210_002
+)
+
+var EventTypes = []string{"movie.created", "movie.updated", "movie.deleted", "season.created", "episode.created", "user.registered"}
+
+// OutboxEvent is a domain event. ID identifies the event; Seq is its position
+// in the published stream and is zero until the relay has published it.
+type OutboxEvent struct {
+	ID        int64           `json:"id"`
+	Seq       int64           `json:"-"`
+	CreatedAt time.Time       `json:"occurred_at"`
+	Event     string          `json:"event"`
+	Payload   json.RawMessage `json:"data"`
+}
+
+func insertOutboxEvent(ctx context.Context, tx *sql.Tx, event string, payload any) error {
+	js, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, `INSERT INTO outbox (event, payload) VALUES ($1, $2)`, event, js)
+	return err
+}
+
+type OutboxLease struct {
+	conn *sql.Conn
+}
+
+func (l *OutboxLease) Alive() bool {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	return l.conn.PingContext(ctx) == nil
+}
+
+func (l *OutboxLease) Release() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	_, err := l.conn.ExecContext(ctx, `SELECT pg_advisory_unlock($1)`, outboxLockKey)
+	closeErr := l.conn.Close()
+	if err != nil {
+		return err
+	}
+	return closeErr
+}
+
+type OutboxModel struct {
+	DB *sql.DB
+}
+
+func (m OutboxModel) TryLead() (*OutboxLease, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	conn, err := m.DB.Conn(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	var acquired bool
+	err = conn.QueryRowContext(ctx, `SELECT pg_try_advisory_lock($1)`, outboxLockKey).Scan(&acquired)
+	if err != nil || !acquired {
+		conn.Close()
+		return nil, err
+	}
+	return &OutboxLease{conn: conn}, nil
+}
+
+func (m OutboxModel) GetUnpublished(limit int) ([]*OutboxEvent, error) {
+	stmt := `
+		SELECT id, created_at, event, payload
+		FROM outbox
+		WHERE published_at IS NULL
+		ORDER BY id
+		LIMIT $1
+	`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	rows, err := m.DB.QueryContext(ctx, stmt, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	events := make([]*OutboxEvent, 0, limit)
+	for rows.Next() {
+		var event OutboxEvent
+		err := rows.Scan(&event.ID, &event.CreatedAt, &event.Event, &event.Payload)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, &event)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+// MarkPublished numbers the events in the published stream. Outbox ids are
+// allocated at insert but become visible at commit, so a reader that follows
+// ids can step past an event whose transaction commits late. The sequence is
+// assigned while holding a transaction-scoped lock instead, which makes the
+// order in which published_seq values become visible match their order.
+func (m OutboxModel) MarkPublished(ids []int64) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	_, err = tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock($1)`, outboxPublishLockKey)
+	if err != nil {
+		return err
+	}
+	stmt := `
+		UPDATE outbox
+		SET published_at = NOW(), published_seq = numbered.seq
+		FROM (
+			SELECT id, nextval('outbox_published_seq') AS seq
+			FROM (SELECT id FROM outbox WHERE id = ANY($1) AND published_seq IS NULL ORDER BY id) pending
+		) numbered
+		WHERE outbox.id = numbered.id
+	`
+	_, err = tx.ExecContext(ctx, stmt, pq.Array(ids))
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, `SELECT pg_notify($1, '')`, OutboxPublishedChannel)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (m OutboxModel) DeletePublished(olderThan time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	stmt := `DELETE FROM outbox WHERE published_at < NOW() - $1 * interval '1 second'`
+	_, err := m.DB.ExecContext(ctx, stmt, olderThan.Seconds())
+	return err
+}
+
+func (m OutboxModel) GetAfter(seq int64, limit int) ([]*OutboxEvent, error) {
+	stmt := `
+		SELECT id, published_seq, created_at, event, payload
+		FROM outbox
+		WHERE published_seq > $1
+		ORDER BY published_seq
+		LIMIT $2
+	`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	rows, err := m.DB.QueryContext(ctx, stmt, seq, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	events := make([]*OutboxEvent, 0, limit)
+	for rows.Next() {
+		var event OutboxEvent
+		err := rows.Scan(&event.ID, &event.Seq, &event.CreatedAt, &event.Event, &event.Payload)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, &event)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+func (m OutboxModel) LatestSeq() (int64, error) {
+	var seq int64
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	err := m.DB.QueryRowContext(ctx, `SELECT COALESCE(max(published_seq), 0) FROM outbox`).Scan(&seq)
+	return seq, err
+}
+
+type MockOutboxModel struct{}
+
+func (m MockOutboxModel) TryLead() (*OutboxLease, error) {
+	return nil, nil
+}
+
+func (m MockOutboxModel) GetUnpublished(limit int) ([]*OutboxEvent, error) {
+	return nil, nil
+}
+
+func (m MockOutboxModel) GetAfter(seq int64, limit int) ([]*OutboxEvent, error) {
+	return nil, nil
+}
+
+func (m MockOutboxModel) LatestSeq() (int64, error) {
+	return 0, nil
+}
+
+func (m MockOutboxModel) MarkPublished(ids []int64) error {
+	return nil
+}
+
+func (m MockOutboxModel) DeletePublished(olderThan time.Duration) error {
+	return nil
+}
@@ -0,0 +1,74 @@
+package data
+
+import (
+	"testing"
+	"time"
+)
+
+func TestOutboxDeletePublished(t *testing.T) {
+	db := newTestDB(t)
+	var old, recent, pending int64
+	stmt := `INSERT INTO outbox (event, payload, published_at) VALUES ('movie.created', '{}', $1) RETURNING id`
+	for _, row := range []struct {
+		id          *int64
+		publishedAt any
+	}{
+		{&old, time.Now().Add(-48 * time.Hour)},
+		{&recent, time.Now().Add(-time.Hour)},
+		{&pending, nil},
+	} {
+		if err := db.QueryRow(stmt, row.publishedAt).Scan(row.id); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Cleanup(func() { db.Exec(`DELETE FROM outbox WHERE id IN ($1, $2, $3)`, old, recent, pending) })
+
+	err := OutboxModel{DB: db}.DeletePublished(24 * time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for id, want := range map[int64]bool{old: false, recent: true, pending: true} {
+		var exists bool
+		if err := db.QueryRow(`SELECT EXISTS (SELECT 1 FROM outbox WHERE id = $1)`, id).Scan(&exists); err != nil {
+			t.Fatal(err)
+		}
+		if exists != want {
+			t.Errorf("event %d exists = %t, want %t", id, exists, want)
+		}
+	}
+}
+
+func TestOutboxPublishedSequence(t *testing.T) {
+	db := newTestDB(t)
+	m := OutboxModel{DB: db}
+	var first, second int64
+	stmt := `INSERT INTO outbox (event, payload) VALUES ('movie.created', '{}') RETURNING id`
+	for _, id := range []*int64{&first, &second} {
+		if err := db.QueryRow(stmt).Scan(id); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Cleanup(func() { db.Exec(`DELETE FROM outbox WHERE id IN ($1, $2)`, first, second) })
+	after, err := m.LatestSeq()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Publishing the later id first, as the relay does when the earlier
+	// transaction commits late, puts it first in the stream.
+	for _, ids := range [][]int64{{second}, {first}, {first}} {
+		if err := m.MarkPublished(ids); err != nil {
+			t.Fatal(err)
+		}
+	}
+	events, err := m.GetAfter(after, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 2 || events[0].ID != second || events[1].ID != first {
+		t.Fatalf("published order = %+v, want %d then %d", events, second, first)
+	}
+	if events[0].Seq <= after || events[1].Seq <= events[0].Seq {
+		t.Errorf("sequences = %d, %d after %d", events[0].Seq, events[1].Seq, after)
+	}
+}
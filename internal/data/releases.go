@@ -0,0 +1,181 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"regexp"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/noonacedia/cinematrique/internal/validator"
+)
+
+var ErrDuplicateRelease = errors.New("duplicate release")
+
+var (
+	CountryRx    = regexp.MustCompile(`^[A-Z]{2}$`)
+	ReleaseTypes = []string{"theatrical", "digital", "physical"}
+)
+
+type Release struct {
+	ID            int64  `json:"id"`
+	MovieID       int64  `json:"-"`
+	Country       string `json:"country"`
+	Date          Date   `json:"date"`
+	Type          string `json:"type"`
+	Certification string `json:"certification,omitempty"`
+}
+
+type ReleaseModel struct {
+	DB *sql.DB
+}
+
+func insertRelease(ctx context.Context, tx *sql.Tx, release *Release) error {
+	stmt := `
+		INSERT INTO releases (movie_id, country, date, type, certification)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id
+	`
+	args := []any{release.MovieID, release.Country, release.Date, release.Type, release.Certification}
+	err := tx.QueryRowContext(ctx, stmt, args...).Scan(&release.ID)
+	if err != nil {
+		var pqErr *pq.Error
+		switch {
+		case errors.As(err, &pqErr) && pqErr.Code == "23505":
+			return ErrDuplicateRelease
+		default:
+			return err
+		}
+	}
+	return nil
+}
+
+func (m ReleaseModel) Insert(release *Release) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	err = insertRelease(ctx, tx, release)
+	if err != nil {
+		return err
+	}
+	err = notifyMovieChange(ctx, tx, MovieChangeUpdated, release.MovieID, 0)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (m ReleaseModel) GetAllForMovies(movieIDs []int64) (map[int64][]*Release, error) {
+	releases := make(map[int64][]*Release)
+	if len(movieIDs) == 0 {
+		return releases, nil
+	}
+	stmt := `
+		SELECT id, movie_id, country, date, type, certification
+		FROM releases
+		WHERE movie_id = ANY($1)
+		ORDER BY movie_id, date, country, type
+	`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	rows, err := m.DB.QueryContext(ctx, stmt, pq.Array(movieIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var release Release
+		err := rows.Scan(
+			&release.ID,
+			&release.MovieID,
+			&release.Country,
+			&release.Date,
+			&release.Type,
+			&release.Certification,
+		)
+		if err != nil {
+			return nil, err
+		}
+		releases[release.MovieID] = append(releases[release.MovieID], &release)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return releases, nil
+}
+
+func (m ReleaseModel) Delete(id, movieID int64) error {
+	if id < 1 {
+		return ErrRecordNotFound
+	}
+	stmt := `
+		DELETE FROM releases
+		WHERE id = $1 AND movie_id = $2
+	`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	result, err := tx.ExecContext(ctx, stmt, id, movieID)
+	if err != nil {
+		return err
+	}
+	affectedRows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affectedRows == 0 {
+		return ErrRecordNotFound
+	}
+	err = notifyMovieChange(ctx, tx, MovieChangeUpdated, movieID, 0)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+type MockReleaseModel struct{}
+
+func (m MockReleaseModel) Insert(release *Release) error {
+	return nil
+}
+
+func (m MockReleaseModel) GetAllForMovies(movieIDs []int64) (map[int64][]*Release, error) {
+	return nil, nil
+}
+
+func (m MockReleaseModel) Delete(id, movieID int64) error {
+	return nil
+}
+
+func EarliestReleaseYear(releases []*Release) int32 {
+	var earliest time.Time
+	for _, release := range releases {
+		if release == nil {
+			continue
+		}
+		if earliest.IsZero() || release.Date.Before(earliest) {
+			earliest = release.Date.Time
+		}
+	}
+	if earliest.IsZero() {
+		return 0
+	}
+	return int32(earliest.Year())
+}
+
+func ValidateRelease(v *validator.Validator, release *Release) {
+	v.Check(validator.Matches(release.Country, CountryRx), "country", "must be an ISO 3166-1 alpha-2 country code")
+	v.Check(!release.Date.IsZero(), "date", "must be provided")
+	v.Check(release.Date.Year() >= 1888, "date", "must not be before 1888")
+	v.Check(validator.In(release.Type, ReleaseTypes...), "type", "must be one of theatrical, digital or physical")
+	v.Check(len(release.Certification) <= 20, "certification", "must not be more than 20 bytes long")
+}
@@ -1,18 +1,116 @@
 package data
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
+	"reflect"
+	"regexp"
 	"strconv"
 	"strings"
 )
 
 var ErrInvalidRuntimeFormat = errors.New("invalid runtime format")
 
+type RuntimeFormat string
+
+const (
+	RuntimeFormatMins    RuntimeFormat = "mins"
+	RuntimeFormatMinutes RuntimeFormat = "minutes"
+	RuntimeFormatISO8601 RuntimeFormat = "iso8601"
+	RuntimeFormatHuman   RuntimeFormat = "human"
+)
+
+var RuntimeFormats = []string{
+	string(RuntimeFormatMins),
+	string(RuntimeFormatMinutes),
+	string(RuntimeFormatISO8601),
+	string(RuntimeFormatHuman),
+}
+
+var (
+	minsRuntimeRx    = regexp.MustCompile(`^(\d+)\s*(?:mins?|minutes?)?$`)
+	iso8601RuntimeRx = regexp.MustCompile(`^PT(?:(\d+)H)?(?:(\d+)M)?(?:(\d+)S)?$`)
+	humanRuntimeRx   = regexp.MustCompile(`^(?:(\d+)\s*h(?:rs?|ours?)?)?\s*(?:(\d+)\s*m(?:ins?|inutes?)?)?$`)
+)
+
 type Runtime int32
 
+func ParseRuntime(s string) (Runtime, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, ErrInvalidRuntimeFormat
+	}
+	if match := minsRuntimeRx.FindStringSubmatch(s); match != nil {
+		return runtimeFromParts(match[1], "", "")
+	}
+	if match := iso8601RuntimeRx.FindStringSubmatch(strings.ToUpper(s)); match != nil {
+		if match[1] == "" && match[2] == "" && match[3] == "" {
+			return 0, ErrInvalidRuntimeFormat
+		}
+		return runtimeFromParts(match[2], match[1], match[3])
+	}
+	if match := humanRuntimeRx.FindStringSubmatch(strings.ToLower(s)); match != nil {
+		if match[1] == "" && match[2] == "" {
+			return 0, ErrInvalidRuntimeFormat
+		}
+		return runtimeFromParts(match[2], match[1], "")
+	}
+	return 0, ErrInvalidRuntimeFormat
+}
+
+func runtimeFromParts(minutes, hours, seconds string) (Runtime, error) {
+	var total int64
+	for _, part := range []struct {
+		value      string
+		multiplier int64
+	}{{hours, 3600}, {minutes, 60}, {seconds, 1}} {
+		if part.value == "" {
+			continue
+		}
+		n, err := strconv.ParseInt(part.value, 10, 32)
+		if err != nil {
+			return 0, ErrInvalidRuntimeFormat
+		}
+		total += n * part.multiplier
+	}
+	minutesTotal := (total + 30) / 60
+	if minutesTotal > 1<<31-1 {
+		return 0, ErrInvalidRuntimeFormat
+	}
+	return Runtime(minutesTotal), nil
+}
+
+func (r Runtime) Format(format RuntimeFormat) any {
+	hours, minutes := int32(r)/60, int32(r)%60
+	switch format {
+	case RuntimeFormatMinutes:
+		return int32(r)
+	case RuntimeFormatISO8601:
+		switch {
+		case hours == 0:
+			return fmt.Sprintf("PT%dM", minutes)
+		case minutes == 0:
+			return fmt.Sprintf("PT%dH", hours)
+		default:
+			return fmt.Sprintf("PT%dH%dM", hours, minutes)
+		}
+	case RuntimeFormatHuman:
+		switch {
+		case hours == 0:
+			return fmt.Sprintf("%dm", minutes)
+		case minutes == 0:
+			return fmt.Sprintf("%dh", hours)
+		default:
+			return fmt.Sprintf("%dh %dm", hours, minutes)
+		}
+	default:
+		return fmt.Sprintf("%d mins", r)
+	}
+}
+
 func (r Runtime) MarshalJSON() ([]byte, error) {
-	jsonString := fmt.Sprintf("%d mins", r)
+	jsonString := r.Format(RuntimeFormatMins).(string)
 	quotedJSONValue := strconv.Quote(jsonString)
 	return []byte(quotedJSONValue), nil
 }
@@ -20,17 +118,106 @@ func (r Runtime) MarshalJSON() ([]byte, error) {
 func (r *Runtime) UnmarshalJSON(jsonValue []byte) error {
 	unquotedJSONValue, err := strconv.Unquote(string(jsonValue))
 	if err != nil {
-		return ErrInvalidRuntimeFormat
-	}
-	parts := strings.Split(unquotedJSONValue, " ")
-	if len(parts) != 2 || parts[1] != "mins" {
-		return ErrInvalidRuntimeFormat
+		runtimeNum, err := strconv.ParseInt(string(jsonValue), 10, 32)
+		if err != nil {
+			return ErrInvalidRuntimeFormat
+		}
+		*r = Runtime(runtimeNum)
+		return nil
 	}
-
-	runtimeNum, err := strconv.ParseInt(parts[0], 10, 32)
+	runtime, err := ParseRuntime(unquotedJSONValue)
 	if err != nil {
-		return ErrInvalidRuntimeFormat
+		return err
 	}
-	*r = Runtime(runtimeNum)
+	*r = runtime
 	return nil
 }
+
+var (
+	runtimeType   = reflect.TypeFor[Runtime]()
+	marshalerType = reflect.TypeFor[json.Marshaler]()
+)
+
+// FormatRuntimes returns a value that encodes like v except that every
+// Runtime in it is rendered in the given format. Types that implement
+// json.Marshaler are left to their own encoding.
+func FormatRuntimes(v any, format RuntimeFormat) any {
+	if format == "" || format == RuntimeFormatMins {
+		return v
+	}
+	return formatRuntimes(reflect.ValueOf(v), format)
+}
+
+func formatRuntimes(v reflect.Value, format RuntimeFormat) any {
+	if !v.IsValid() {
+		return nil
+	}
+	if v.Type() == runtimeType {
+		return v.Interface().(Runtime).Format(format)
+	}
+	if v.Type().Implements(marshalerType) || (v.CanAddr() && v.Addr().Type().Implements(marshalerType)) {
+		return v.Interface()
+	}
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+		return formatRuntimes(v.Elem(), format)
+	case reflect.Slice:
+		if v.IsNil() || v.Type().Elem().Kind() == reflect.Uint8 {
+			return v.Interface()
+		}
+		fallthrough
+	case reflect.Array:
+		items := make([]any, v.Len())
+		for i := range items {
+			items[i] = formatRuntimes(v.Index(i), format)
+		}
+		return items
+	case reflect.Map:
+		if v.IsNil() || v.Type().Key().Kind() != reflect.String {
+			return v.Interface()
+		}
+		items := make(map[string]any, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			items[iter.Key().String()] = formatRuntimes(iter.Value(), format)
+		}
+		return items
+	case reflect.Struct:
+		fields := make(map[string]any, v.NumField())
+		for i := 0; i < v.NumField(); i++ {
+			field := v.Type().Field(i)
+			if !field.IsExported() {
+				continue
+			}
+			name, opts, _ := strings.Cut(field.Tag.Get("json"), ",")
+			if name == "-" && opts == "" {
+				continue
+			}
+			if name == "" {
+				name = field.Name
+			}
+			if strings.Contains(","+opts+",", ",omitempty,") && isEmptyValue(v.Field(i)) {
+				continue
+			}
+			fields[name] = formatRuntimes(v.Field(i), format)
+		}
+		return fields
+	default:
+		return v.Interface()
+	}
+}
+
+// isEmptyValue mirrors the omitempty rule of encoding/json.
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Struct:
+		return false
+	default:
+		return v.IsZero()
+	}
+}
@@ -0,0 +1,129 @@
+package data
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestParseRuntime(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    Runtime
+		wantErr bool
+	}{
+		{"107", 107, false},
+		{"107 mins", 107, false},
+		{"1 min", 1, false},
+		{"107 minutes", 107, false},
+		{"1h 45m", 105, false},
+		{"2h", 120, false},
+		{"45m", 45, false},
+		{"1 hour 5 minutes", 65, false},
+		{"PT1H45M", 105, false},
+		{"pt1h45m", 105, false},
+		{"PT90S", 2, false},
+		{"", 0, true},
+		{"PT", 0, true},
+		{"-5", 0, true},
+		{"-5 mins", 0, true},
+		{"1h -5m", 0, true},
+		{"two hours", 0, true},
+		{"2147483647", 2147483647, false},
+		{"2147483648", 0, true},
+		{"PT2147483647H", 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ParseRuntime(tt.input)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidRuntimeFormat) {
+					t.Fatalf("got %d, %v, want ErrInvalidRuntimeFormat", got, err)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("got %d, %v, want %d", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestRuntimeUnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    Runtime
+		wantErr bool
+	}{
+		{`107`, 107, false},
+		{`"107 mins"`, 107, false},
+		{`"1h 45m"`, 105, false},
+		{`"PT1H45M"`, 105, false},
+		{`-5`, -5, false},
+		{`2147483648`, 0, true},
+		{`1.5`, 0, true},
+		{`"soon"`, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			var got Runtime
+			err := json.Unmarshal([]byte(tt.input), &got)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("got %d, want an error", got)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("got %d, %v, want %d", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormatRuntimes(t *testing.T) {
+	value := map[string]any{
+		"movie":   &Movie{ID: 1, Title: "Alien", Runtime: 117, Genres: []string{"horror"}},
+		"movies":  []*Movie{{ID: 2, Title: "Heat"}},
+		"episode": Episode{Title: "Pilot", Runtime: 45},
+		"note":    struct{ Runtime string }{Runtime: "90 mins"},
+		"meta":    map[string]any{"runtime": "1h 30m", "projected": Runtime(60)},
+	}
+	got, err := json.Marshal(FormatRuntimes(value, RuntimeFormatISO8601))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded struct {
+		Movie   map[string]any   `json:"movie"`
+		Movies  []map[string]any `json:"movies"`
+		Episode map[string]any   `json:"episode"`
+		Note    map[string]any   `json:"note"`
+		Meta    map[string]any   `json:"meta"`
+	}
+	if err := json.Unmarshal(got, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	checks := []struct {
+		name      string
+		got, want any
+	}{
+		{"movie runtime", decoded.Movie["runtime"], "PT1H57M"},
+		{"movie title", decoded.Movie["title"], "Alien"},
+		{"omitted zero runtime", decoded.Movies[0]["runtime"], nil},
+		{"episode runtime", decoded.Episode["runtime"], "PT45M"},
+		{"unrelated string field", decoded.Note["Runtime"], "90 mins"},
+		{"unrelated runtime key", decoded.Meta["runtime"], "1h 30m"},
+		{"runtime in a map", decoded.Meta["projected"], "PT1H"},
+	}
+	for _, c := range checks {
+		if c.got != c.want {
+			t.Errorf("%s = %v, want %v", c.name, c.got, c.want)
+		}
+	}
+	if _, ok := decoded.Movie["created_at"]; ok {
+		t.Error(`fields tagged json:"-" must stay hidden`)
+	}
+	if _, ok := decoded.Movies[0]["genres"]; ok {
+		t.Error("empty omitempty fields must stay omitted")
+	}
+}
@@ -0,0 +1,277 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/noonacedia/cinematrique/internal/validator"
+)
+
+var (
+	ErrDuplicateSeason  = errors.New("duplicate season")
+	ErrDuplicateEpisode = errors.New("duplicate episode")
+)
+
+var MovieTypes = []string{"movie", "series"}
+
+type Season struct {
+	ID           int64      `json:"id"`
+	SeriesID     int64      `json:"series_id"`
+	Number       int32      `json:"number"`
+	Name         string     `json:"name,omitempty"`
+	AirDate      *Date      `json:"air_date,omitempty"`
+	EpisodeCount int        `json:"episode_count"`
+	Episodes     []*Episode `json:"episodes,omitempty"`
+}
+
+type Episode struct {
+	ID           int64   `json:"id"`
+	SeasonID     int64   `json:"-"`
+	SeasonNumber int32   `json:"season_number"`
+	Number       int32   `json:"number"`
+	Title        string  `json:"title"`
+	Runtime      Runtime `json:"runtime,omitempty"`
+	AirDate      *Date   `json:"air_date,omitempty"`
+}
+
+type SeriesModel struct {
+	DB *sql.DB
+}
+
+func (m SeriesModel) InsertSeason(season *Season) error {
+	stmt := `
+		INSERT INTO seasons (series_id, number, name, air_date)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id
+	`
+	args := []any{season.SeriesID, season.Number, season.Name, season.AirDate}
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	err = tx.QueryRowContext(ctx, stmt, args...).Scan(&season.ID)
+	if err != nil {
+		var pqErr *pq.Error
+		switch {
+		case errors.As(err, &pqErr) && pqErr.Code == "23505":
+			return ErrDuplicateSeason
+		default:
+			return err
+		}
+	}
+	err = notifyMovieChange(ctx, tx, MovieChangeUpdated, season.SeriesID, 0)
+	if err != nil {
+		return err
+	}
+	err = insertOutboxEvent(ctx, tx, "season.created", season)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (m SeriesModel) GetSeasons(seriesID int64) ([]*Season, error) {
+	stmt := `
+		SELECT seasons.id, seasons.series_id, seasons.number, seasons.name, seasons.air_date,
+			(SELECT COUNT(*) FROM episodes WHERE episodes.season_id = seasons.id)
+		FROM seasons
+		WHERE seasons.series_id = $1
+		ORDER BY seasons.number
+	`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	rows, err := m.DB.QueryContext(ctx, stmt, seriesID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	seasons := make([]*Season, 0)
+	for rows.Next() {
+		var season Season
+		err := rows.Scan(
+			&season.ID,
+			&season.SeriesID,
+			&season.Number,
+			&season.Name,
+			&season.AirDate,
+			&season.EpisodeCount,
+		)
+		if err != nil {
+			return nil, err
+		}
+		seasons = append(seasons, &season)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return seasons, nil
+}
+
+func (m SeriesModel) GetSeason(seriesID int64, number int32) (*Season, error) {
+	stmt := `
+		SELECT id, series_id, number, name, air_date
+		FROM seasons
+		WHERE series_id = $1 AND number = $2
+	`
+	var season Season
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	err := m.DB.QueryRowContext(ctx, stmt, seriesID, number).Scan(
+		&season.ID,
+		&season.SeriesID,
+		&season.Number,
+		&season.Name,
+		&season.AirDate,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+	stmt = `
+		SELECT id, season_id, number, title, runtime, air_date
+		FROM episodes
+		WHERE season_id = $1
+		ORDER BY number
+	`
+	rows, err := m.DB.QueryContext(ctx, stmt, season.ID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	season.Episodes = make([]*Episode, 0)
+	for rows.Next() {
+		episode := Episode{SeasonNumber: season.Number}
+		err := rows.Scan(
+			&episode.ID,
+			&episode.SeasonID,
+			&episode.Number,
+			&episode.Title,
+			&episode.Runtime,
+			&episode.AirDate,
+		)
+		if err != nil {
+			return nil, err
+		}
+		season.Episodes = append(season.Episodes, &episode)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	season.EpisodeCount = len(season.Episodes)
+	return &season, nil
+}
+
+func (m SeriesModel) InsertEpisode(episode *Episode) error {
+	stmt := `
+		INSERT INTO episodes (season_id, number, title, runtime, air_date)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id
+	`
+	args := []any{episode.SeasonID, episode.Number, episode.Title, episode.Runtime, episode.AirDate}
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	err = tx.QueryRowContext(ctx, stmt, args...).Scan(&episode.ID)
+	if err != nil {
+		var pqErr *pq.Error
+		switch {
+		case errors.As(err, &pqErr) && pqErr.Code == "23505":
+			return ErrDuplicateEpisode
+		default:
+			return err
+		}
+	}
+	var seriesID int64
+	err = tx.QueryRowContext(ctx, `SELECT series_id FROM seasons WHERE id = $1`, episode.SeasonID).Scan(&seriesID)
+	if err != nil {
+		return err
+	}
+	err = notifyMovieChange(ctx, tx, MovieChangeUpdated, seriesID, 0)
+	if err != nil {
+		return err
+	}
+	err = insertOutboxEvent(ctx, tx, "episode.created", map[string]any{"series_id": seriesID, "episode": episode})
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (m SeriesModel) GetEpisode(seriesID int64, seasonNumber, number int32) (*Episode, error) {
+	stmt := `
+		SELECT episodes.id, episodes.season_id, seasons.number, episodes.number, episodes.title, episodes.runtime, episodes.air_date
+		FROM episodes
+		JOIN seasons ON seasons.id = episodes.season_id
+		WHERE seasons.series_id = $1 AND seasons.number = $2 AND episodes.number = $3
+	`
+	var episode Episode
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	err := m.DB.QueryRowContext(ctx, stmt, seriesID, seasonNumber, number).Scan(
+		&episode.ID,
+		&episode.SeasonID,
+		&episode.SeasonNumber,
+		&episode.Number,
+		&episode.Title,
+		&episode.Runtime,
+		&episode.AirDate,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+	return &episode, nil
+}
+
+type MockSeriesModel struct{}
+
+func (m MockSeriesModel) InsertSeason(season *Season) error {
+	return nil
+}
+
+func (m MockSeriesModel) GetSeasons(seriesID int64) ([]*Season, error) {
+	return nil, nil
+}
+
+func (m MockSeriesModel) GetSeason(seriesID int64, number int32) (*Season, error) {
+	return nil, nil
+}
+
+func (m MockSeriesModel) InsertEpisode(episode *Episode) error {
+	return nil
+}
+
+func (m MockSeriesModel) GetEpisode(seriesID int64, seasonNumber, number int32) (*Episode, error) {
+	return nil, nil
+}
+
+func ValidateSeason(v *validator.Validator, season *Season) {
+	v.Check(season.Number >= 0, "number", "must not be negative")
+	v.Check(season.Number <= 1_000, "number", "must not be more than 1000")
+	v.Check(len(season.Name) <= 500, "name", "must not be more than 500 bytes long")
+}
+
+func ValidateEpisode(v *validator.Validator, episode *Episode) {
+	v.Check(episode.Number > 0, "number", "must be positive")
+	v.Check(episode.Title != "", "title", "must be provided")
+	v.Check(len(episode.Title) <= 500, "title", "must not be more than 500 bytes long")
+	v.Check(episode.Runtime >= 0, "runtime", "must not be negative")
+}
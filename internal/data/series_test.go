@@ -0,0 +1,67 @@
+package data
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestSeriesInsertsWriteOutboxEvents(t *testing.T) {
+	db := newTestDB(t)
+	m := SeriesModel{DB: db}
+	var seriesID, after int64
+	stmt := `INSERT INTO movies (type, title, year, runtime, genres) VALUES ('series', 'Outbox Series', 2002, 0, '{drama}') RETURNING id`
+	if err := db.QueryRow(stmt).Scan(&seriesID); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Exec(`DELETE FROM movies WHERE id = $1`, seriesID) })
+	if err := db.QueryRow(`SELECT COALESCE(MAX(id), 0) FROM outbox`).Scan(&after); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		db.Exec(`DELETE FROM outbox WHERE id > $1 AND (payload->>'series_id')::bigint = $2`, after, seriesID)
+	})
+
+	season := &Season{SeriesID: seriesID, Number: 1}
+	err := m.InsertSeason(season)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = m.InsertSeason(&Season{SeriesID: seriesID, Number: 1})
+	if !errors.Is(err, ErrDuplicateSeason) {
+		t.Fatalf("duplicate season error = %v, want ErrDuplicateSeason", err)
+	}
+	err = m.InsertEpisode(&Episode{SeasonID: season.ID, SeasonNumber: 1, Number: 1, Title: "Pilot"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rows, err := db.Query(`SELECT event, payload FROM outbox WHERE id > $1 ORDER BY id`, after)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var events []string
+	for rows.Next() {
+		var event string
+		var payload []byte
+		if err := rows.Scan(&event, &payload); err != nil {
+			t.Fatal(err)
+		}
+		var body struct {
+			SeriesID int64 `json:"series_id"`
+		}
+		if err := json.Unmarshal(payload, &body); err != nil {
+			t.Fatal(err)
+		}
+		if body.SeriesID == seriesID {
+			events = append(events, event)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 2 || events[0] != "season.created" || events[1] != "episode.created" {
+		t.Errorf("events = %v, want one season.created and one episode.created", events)
+	}
+}
@@ -65,7 +65,12 @@ func (m UserModel) Insert(u *User) error {
 	args := []interface{}{u.Name, u.Email, u.Password.hash, u.Activated}
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
-	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&u.ID, &u.CreatedAt, &u.Version)
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	err = tx.QueryRowContext(ctx, query, args...).Scan(&u.ID, &u.CreatedAt, &u.Version)
 	if err != nil {
 		switch {
 		case err.Error() == `pq: duplicate key value violates unique constraint "users_email_key"`:
@@ -74,7 +79,11 @@ func (m UserModel) Insert(u *User) error {
 			return err
 		}
 	}
-	return nil
+	err = insertOutboxEvent(ctx, tx, "user.registered", u)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
 }
 
 func (m UserModel) GetByEmail(email string) (*User, error) {
@@ -0,0 +1,160 @@
+package msgpack
+
+import (
+	"encoding/json"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+
+	reference "github.com/vmihailenco/msgpack/v5"
+)
+
+// normalize maps the numeric types a decoder may produce onto int64 and
+// float64 so values can be compared regardless of the width chosen on the
+// wire. Integral float64s are expected back as integers because Marshal
+// writes them in the integer family.
+func normalize(v any) any {
+	switch v := v.(type) {
+	case int:
+		return int64(v)
+	case int8:
+		return int64(v)
+	case int16:
+		return int64(v)
+	case int32:
+		return int64(v)
+	case uint8:
+		return int64(v)
+	case uint16:
+		return int64(v)
+	case uint32:
+		return int64(v)
+	case uint64:
+		return int64(v)
+	case float32:
+		return float64(v)
+	case float64:
+		if v == math.Trunc(v) && math.Abs(v) < 1<<53 {
+			return int64(v)
+		}
+		return v
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i
+		}
+		f, _ := v.Float64()
+		return f
+	case []any:
+		items := make([]any, len(v))
+		for i, item := range v {
+			items[i] = normalize(item)
+		}
+		return items
+	case map[string]any:
+		m := make(map[string]any, len(v))
+		for key, value := range v {
+			m[key] = normalize(value)
+		}
+		return m
+	}
+	return v
+}
+
+func sequence(n int) []any {
+	items := make([]any, n)
+	for i := range items {
+		items[i] = float64(i)
+	}
+	return items
+}
+
+func keyed(n int) map[string]any {
+	m := make(map[string]any, n)
+	for i := range n {
+		m[strings.Repeat("k", i+1)] = i%2 == 0
+	}
+	return m
+}
+
+func TestMarshalDecodesWithReferenceLibrary(t *testing.T) {
+	tests := []struct {
+		name  string
+		value any
+	}{
+		{"nil", nil},
+		{"true", true},
+		{"false", false},
+		{"positive fixint", float64(127)},
+		{"uint8", float64(255)},
+		{"uint16", float64(math.MaxUint16)},
+		{"uint32", float64(math.MaxUint32)},
+		{"uint64", float64(1<<53 - 1)},
+		{"negative fixint", float64(-32)},
+		{"int8", float64(math.MinInt8)},
+		{"int16", float64(math.MinInt16)},
+		{"int32", float64(math.MinInt32)},
+		{"int64", json.Number("-9223372036854775808")},
+		{"max int64", json.Number("9223372036854775807")},
+		{"float", 102.5},
+		{"large float", 1e300},
+		{"number float", json.Number("0.25")},
+		{"int", 42},
+		{"fixstr", strings.Repeat("a", 31)},
+		{"str8", strings.Repeat("é", 100)},
+		{"str16", strings.Repeat("b", math.MaxUint16)},
+		{"str32", strings.Repeat("c", math.MaxUint16+1)},
+		{"fixarray", sequence(15)},
+		{"array16", sequence(16)},
+		{"array32", sequence(math.MaxUint16 + 1)},
+		{"fixmap", keyed(15)},
+		{"map16", keyed(16)},
+		{"envelope", map[string]any{
+			"movies": []any{
+				map[string]any{"id": json.Number("1"), "title": "Moana", "runtime": "107 mins", "genres": []any{"animation"}},
+			},
+			"metadata": map[string]any{},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, err := Marshal(tt.value)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got any
+			err = reference.Unmarshal(b, &got)
+			if err != nil {
+				t.Fatalf("reference decoder rejected %x: %v", b[:min(len(b), 16)], err)
+			}
+			if want := normalize(tt.value); !reflect.DeepEqual(normalize(got), want) {
+				t.Errorf("round trip = %.200v, want %.200v", normalize(got), want)
+			}
+		})
+	}
+}
+
+func TestMarshalSortsMapKeys(t *testing.T) {
+	a, err := Marshal(map[string]any{"b": 1, "a": 2, "c": 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := Marshal(map[string]any{"c": 3, "a": 2, "b": 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(a) != string(b) {
+		t.Errorf("encodings differ: %x and %x", a, b)
+	}
+}
+
+func TestMarshalRejectsUnsupportedTypes(t *testing.T) {
+	_, err := Marshal(map[string]any{"at": struct{}{}})
+	if err == nil {
+		t.Fatal("Marshal accepted a struct")
+	}
+	_, err = Marshal(json.Number("1e400"))
+	if err == nil {
+		t.Fatal("Marshal accepted an out of range number")
+	}
+}
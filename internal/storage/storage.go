@@ -0,0 +1,23 @@
+package storage
+
+import (
+	"errors"
+	"io"
+	"time"
+)
+
+var (
+	ErrBlobNotFound = errors.New("blob not found")
+	ErrInvalidKey   = errors.New("invalid blob key")
+)
+
+type Blob interface {
+	io.ReadSeekCloser
+	ModTime() time.Time
+}
+
+type BlobStore interface {
+	Put(key string, r io.Reader) error
+	Get(key string) (Blob, error)
+	Delete(key string) error
+}
@@ -0,0 +1,17 @@
+CREATE TABLE IF NOT EXISTS movie_titles (
+  id bigserial PRIMARY KEY,
+  movie_id bigint NOT NULL REFERENCES movies ON DELETE CASCADE,
+  locale text NOT NULL,
+  kind text NOT NULL CHECK (kind IN ('localized', 'alternate', 'original')),
+  title text NOT NULL,
+  ts_config regconfig NOT NULL DEFAULT 'simple',
+  UNIQUE (movie_id, locale, kind, title)
+);
+
+CREATE INDEX IF NOT EXISTS movie_titles_movie_id_idx ON movie_titles (movie_id, locale);
+
+CREATE INDEX IF NOT EXISTS movie_titles_title_idx ON movie_titles USING GIN (to_tsvector(ts_config, title));
+
+CREATE INDEX IF NOT EXISTS movie_titles_title_simple_idx ON movie_titles USING GIN (to_tsvector('simple', title));
+
+CREATE INDEX IF NOT EXISTS movie_titles_title_trgm_idx ON movie_titles USING GIN (title gin_trgm_ops);
@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS movie_images (
+  id bigserial PRIMARY KEY,
+  movie_id bigint NOT NULL REFERENCES movies ON DELETE CASCADE,
+  created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+  kind text NOT NULL CHECK (kind IN ('poster', 'still')),
+  content_type text NOT NULL,
+  key text UNIQUE NOT NULL,
+  width integer NOT NULL,
+  height integer NOT NULL,
+  size bigint NOT NULL,
+  thumbnail_key text,
+  thumbnail_width integer,
+  thumbnail_height integer
+);
+
+CREATE INDEX IF NOT EXISTS movie_images_movie_id_idx ON movie_images (movie_id);
@@ -0,0 +1,20 @@
+CREATE MATERIALIZED VIEW IF NOT EXISTS movie_similarities AS
+SELECT movie_id, similar_id, score
+FROM (
+  SELECT a.id AS movie_id, b.id AS similar_id,
+    (0.8 * s.jaccard + 0.2 * s.year_proximity)::float8 AS score,
+    row_number() OVER (PARTITION BY a.id ORDER BY 0.8 * s.jaccard + 0.2 * s.year_proximity DESC, b.id) AS rank
+  FROM movies a
+  JOIN movies b ON a.id <> b.id AND a.genres && b.genres
+  CROSS JOIN LATERAL (
+    SELECT
+      (SELECT count(*) FROM (SELECT unnest(a.genres) INTERSECT SELECT unnest(b.genres)) AS i)::float8 /
+      (SELECT count(*) FROM (SELECT unnest(a.genres) UNION SELECT unnest(b.genres)) AS u) AS jaccard,
+      1.0 / (1.0 + abs(a.year - b.year) / 5.0) AS year_proximity
+  ) AS s
+) AS ranked
+WHERE rank <= 50;
+
+CREATE UNIQUE INDEX IF NOT EXISTS movie_similarities_pair_idx ON movie_similarities (movie_id, similar_id);
+
+CREATE INDEX IF NOT EXISTS movie_similarities_score_idx ON movie_similarities (movie_id, score DESC);
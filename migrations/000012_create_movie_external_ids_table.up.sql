@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS movie_external_ids (
+  movie_id bigint NOT NULL REFERENCES movies ON DELETE CASCADE,
+  source text NOT NULL,
+  value text NOT NULL,
+  PRIMARY KEY (movie_id, source),
+  UNIQUE (source, value)
+);
@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS movie_redirects (
+  movie_id bigint PRIMARY KEY,
+  target_id bigint NOT NULL REFERENCES movies ON DELETE CASCADE,
+  created_at timestamp(0) with time zone NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS movie_redirects_target_id_idx ON movie_redirects (target_id);
+
+CREATE INDEX IF NOT EXISTS movies_normalized_title_idx ON movies (lower(regexp_replace(title, '[^[:alnum:]]+', '', 'g')), year);
@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS releases (
+  id bigserial PRIMARY KEY,
+  movie_id bigint NOT NULL REFERENCES movies ON DELETE CASCADE,
+  country char(2) NOT NULL,
+  date date NOT NULL,
+  type text NOT NULL CHECK (type IN ('theatrical', 'digital', 'physical')),
+  certification text NOT NULL DEFAULT '',
+  UNIQUE (movie_id, country, type)
+);
+
+CREATE INDEX IF NOT EXISTS releases_country_date_idx ON releases (country, date);
+
+CREATE INDEX IF NOT EXISTS releases_movie_id_idx ON releases (movie_id);
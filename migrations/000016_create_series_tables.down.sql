@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS episodes;
+
+DROP TABLE IF EXISTS seasons;
+
+ALTER TABLE movies DROP COLUMN IF EXISTS type;
@@ -0,0 +1,22 @@
+ALTER TABLE movies ADD COLUMN IF NOT EXISTS type text NOT NULL DEFAULT 'movie' CHECK (type IN ('movie', 'series'));
+
+CREATE INDEX IF NOT EXISTS movies_type_idx ON movies (type);
+
+CREATE TABLE IF NOT EXISTS seasons (
+  id bigserial PRIMARY KEY,
+  series_id bigint NOT NULL REFERENCES movies ON DELETE CASCADE,
+  number integer NOT NULL CHECK (number >= 0),
+  name text NOT NULL DEFAULT '',
+  air_date date,
+  UNIQUE (series_id, number)
+);
+
+CREATE TABLE IF NOT EXISTS episodes (
+  id bigserial PRIMARY KEY,
+  season_id bigint NOT NULL REFERENCES seasons ON DELETE CASCADE,
+  number integer NOT NULL CHECK (number > 0),
+  title text NOT NULL,
+  runtime integer NOT NULL DEFAULT 0 CHECK (runtime >= 0),
+  air_date date,
+  UNIQUE (season_id, number)
+);
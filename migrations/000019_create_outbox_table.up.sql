@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS outbox (
+  id bigserial PRIMARY KEY,
+  created_at timestamp with time zone NOT NULL DEFAULT NOW(),
+  event text NOT NULL,
+  payload jsonb NOT NULL,
+  published_at timestamp with time zone
+);
+
+CREATE INDEX IF NOT EXISTS outbox_unpublished_idx ON outbox (id) WHERE published_at IS NULL;
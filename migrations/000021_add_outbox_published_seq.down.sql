@@ -0,0 +1,3 @@
+ALTER TABLE outbox DROP COLUMN IF EXISTS published_seq;
+
+DROP SEQUENCE IF EXISTS outbox_published_seq;
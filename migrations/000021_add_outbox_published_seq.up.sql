@@ -0,0 +1,9 @@
+CREATE SEQUENCE IF NOT EXISTS outbox_published_seq;
+
+ALTER TABLE outbox ADD COLUMN IF NOT EXISTS published_seq bigint UNIQUE;
+
+UPDATE outbox SET published_seq = numbered.seq
+FROM (SELECT id, row_number() OVER (ORDER BY id) AS seq FROM outbox WHERE published_at IS NOT NULL) numbered
+WHERE outbox.id = numbered.id;
+
+SELECT setval('outbox_published_seq', COALESCE((SELECT max(published_seq) FROM outbox), 0) + 1, false);
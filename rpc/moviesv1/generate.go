@@ -0,0 +1,5 @@
+// Package moviesv1 holds the protobuf messages and gRPC stubs generated from
+// movies.proto.
+package moviesv1
+
+//go:generate protoc -I ../.. --go_out=../.. --go_opt=paths=source_relative --go-grpc_out=../.. --go-grpc_opt=paths=source_relative rpc/moviesv1/movies.proto